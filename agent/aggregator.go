@@ -15,7 +15,13 @@ func NewAggregator(
 
 // Format metrics coming from the MetricsAggregator. Will look like:
 // (metric, timestamp, value, {"tags": ["tag1", "tag2"], ...})
+// Events and service checks are passed through as they are.
 func formatter(m metric.Metric) interface{} {
+	switch v := m.Value.(type) {
+	case metric.Event, metric.ServiceCheck:
+		return v
+	}
+
 	var ret []interface{}
 	ret = append(ret, m.Name)
 	ret = append(ret, m.Timestamp)
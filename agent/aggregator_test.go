@@ -13,3 +13,23 @@ func TestFormatter(t *testing.T) {
 	actual := fmt.Sprintf("%v", formatter(m))
 	assert.Equal(t, actual, "[test.formatter 0 99 map[tags:[test]]]")
 }
+
+func TestFormatterWithEvent(t *testing.T) {
+	e := metric.Event{Title: "test.event"}
+	m := metric.Metric{
+		Name:  "event",
+		Value: e,
+		Type:  "event",
+	}
+	assert.Equal(t, e, formatter(m))
+}
+
+func TestFormatterWithServiceCheck(t *testing.T) {
+	sc := metric.NewServiceCheck("test.can_connect", metric.StatusOK, "", nil)
+	m := metric.Metric{
+		Name:  sc.Check,
+		Value: sc,
+		Type:  "service_check",
+	}
+	assert.Equal(t, sc, formatter(m))
+}
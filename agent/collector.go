@@ -42,7 +42,7 @@ func NewCollector(conf *config.Config) *Collector {
 func (c *Collector) Post(metrics []interface{}) error {
 	start := time.Now()
 	payload := NewPayload(c.conf)
-	payload.Metrics = metrics
+	payload.AddMetrics(metrics)
 
 	if c.shouldSendMetadata() {
 		log.Debug("We should send metadata.")
@@ -9,6 +9,7 @@ import (
 
 	"github.com/cloudinsight/cloudinsight-agent/common/config"
 	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
 	uuid "github.com/nu7hatch/gouuid"
 )
 
@@ -42,6 +43,29 @@ type Payload struct {
 	Events              map[string]interface{} `json:"events,omitempty"`
 }
 
+// AddMetrics puts the formatted metrics into the payload. Events are
+// grouped by their source type name, service checks are sent separately.
+func (p *Payload) AddMetrics(metrics []interface{}) {
+	for _, m := range metrics {
+		switch v := m.(type) {
+		case metric.Event:
+			if p.Events == nil {
+				p.Events = make(map[string]interface{})
+			}
+			sourceType := v.SourceTypeName
+			if sourceType == "" {
+				sourceType = "api"
+			}
+			events, _ := p.Events[sourceType].([]metric.Event)
+			p.Events[sourceType] = append(events, v)
+		case metric.ServiceCheck:
+			p.ServiceChecks = append(p.ServiceChecks, v)
+		default:
+			p.Metrics = append(p.Metrics, m)
+		}
+	}
+}
+
 func getMacAddr() string {
 	interfaces, _ := net.Interfaces()
 	for _, inter := range interfaces {
@@ -3,6 +3,7 @@ package agent
 import (
 	"testing"
 
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,3 +13,20 @@ func TestGetUUID(t *testing.T) {
 	assert.Equal(t, uuid, uuid2)
 	assert.Len(t, uuid, 32)
 }
+
+func TestAddMetrics(t *testing.T) {
+	p := &Payload{}
+	e := metric.Event{
+		Title:          "test",
+		SourceTypeName: "zookeeper",
+	}
+	sc := metric.NewServiceCheck("test.can_connect", metric.StatusOK, "", nil)
+	p.AddMetrics([]interface{}{
+		[]interface{}{"test.metric", 0, 1},
+		e,
+		sc,
+	})
+	assert.Len(t, p.Metrics, 1)
+	assert.Equal(t, []metric.Event{e}, p.Events["zookeeper"])
+	assert.Equal(t, []interface{}{sc}, p.ServiceChecks)
+}
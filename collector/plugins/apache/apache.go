@@ -2,7 +2,10 @@ package apache
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -21,10 +24,17 @@ func NewApache(conf plugin.InitConfig) plugin.Plugin {
 
 // Apache XXX
 type Apache struct {
-	ApacheStatusURL string `yaml:"apache_status_url"`
-	ApacheUser      string `yaml:"apache_user"`
-	ApachePassword  string `yaml:"apache_password"`
-	Tags            []string
+	ApacheStatusURL    string            `yaml:"apache_status_url"`
+	ApacheUser         string            `yaml:"apache_user"`
+	ApachePassword     string            `yaml:"apache_password"`
+	CAPath             string            `yaml:"ca_path"`
+	CertPath           string            `yaml:"cert_path"`
+	KeyPath            string            `yaml:"key_path"`
+	InsecureSkipVerify bool              `yaml:"insecure_skip_verify"`
+	Headers            map[string]string `yaml:"headers"`
+	Tags               []string
+
+	client *http.Client
 }
 
 var (
@@ -43,16 +53,22 @@ var (
 		"Total kBytes":   "apache.net.bytes_per_s",
 		"Total Accesses": "apache.net.request_per_s",
 	}
-)
-
-var tr = &http.Transport{
-	ResponseHeaderTimeout: time.Duration(3 * time.Second),
-}
 
-var client = &http.Client{
-	Transport: tr,
-	Timeout:   time.Duration(4 * time.Second),
-}
+	// SCOREBOARD XXX
+	SCOREBOARD = map[rune]string{
+		'_': "apache.scoreboard.waiting_for_connection",
+		'S': "apache.scoreboard.starting_up",
+		'R': "apache.scoreboard.reading_request",
+		'W': "apache.scoreboard.sending_reply",
+		'K': "apache.scoreboard.keepalive",
+		'D': "apache.scoreboard.dns_lookup",
+		'C': "apache.scoreboard.closing_connection",
+		'L': "apache.scoreboard.logging",
+		'G': "apache.scoreboard.gracefully_finishing",
+		'I': "apache.scoreboard.idle_cleanup",
+		'.': "apache.scoreboard.open_slot",
+	}
+)
 
 // Check XXX
 func (a *Apache) Check(agg metric.Aggregator) error {
@@ -69,8 +85,21 @@ func (a *Apache) Check(agg metric.Aggregator) error {
 	if a.ApacheUser != "" {
 		req.SetBasicAuth(a.ApacheUser, a.ApachePassword)
 	}
+	for key, value := range a.Headers {
+		if strings.EqualFold(key, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(key, value)
+	}
 
-	resp, err := client.Do(req)
+	if a.client == nil {
+		a.client, err = a.newClient()
+		if err != nil {
+			return err
+		}
+	}
+	resp, err := a.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("error making HTTP request to %s: %s", requestURI, err)
 	}
@@ -88,7 +117,7 @@ func (a *Apache) Check(agg metric.Aggregator) error {
 
 			switch key {
 			case "Scoreboard":
-				continue
+				a.collectScoreboard(agg, part)
 			default:
 				value, err := strconv.ParseFloat(part, 64)
 				if err != nil {
@@ -116,6 +145,69 @@ func (a *Apache) Check(agg metric.Aggregator) error {
 	return nil
 }
 
+// collectScoreboard counts the workers by state, each character of the
+// scoreboard is the state of a worker slot.
+func (a *Apache) collectScoreboard(agg metric.Aggregator, scoreboard string) {
+	counts := make(map[rune]int)
+	for state := range SCOREBOARD {
+		counts[state] = 0
+	}
+	var total int
+	for _, state := range scoreboard {
+		if _, ok := SCOREBOARD[state]; !ok {
+			continue
+		}
+		counts[state]++
+		total++
+	}
+	if total == 0 {
+		return
+	}
+
+	for state, count := range counts {
+		agg.Add("gauge", metric.NewMetric(SCOREBOARD[state], count, a.Tags))
+	}
+
+	// The open slots are the workers which could still be started before
+	// reaching MaxRequestWorkers.
+	busy := total - counts['_'] - counts['.']
+	agg.Add("gauge", metric.NewMetric("apache.performance.busy_pct", 100*float64(busy)/float64(total), a.Tags))
+}
+
+func (a *Apache) newClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: a.InsecureSkipVerify,
+	}
+
+	if a.CAPath != "" {
+		ca, err := ioutil.ReadFile(a.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the CA certificate %s: %s", a.CAPath, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No valid certificate found in %s", a.CAPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if a.CertPath != "" {
+		cert, err := tls.LoadX509KeyPair(a.CertPath, a.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load the client certificate %s: %s", a.CertPath, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:       tlsConfig,
+			ResponseHeaderTimeout: time.Duration(3 * time.Second),
+		},
+		Timeout: time.Duration(4 * time.Second),
+	}, nil
+}
+
 func init() {
 	collector.Add("apache", NewApache)
 }
@@ -2,11 +2,15 @@ package apache
 
 import (
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/stretchr/testify/assert"
 )
 
 var apacheStatus = `
@@ -76,11 +80,63 @@ func TestApacheCheck(t *testing.T) {
 		"apache.net.bytes":                5213701865 * 1024,
 		"apache.net.hits":                 129811861,
 	}
-	testutil.AssertCheckWithMetrics(t, a.Check, 6, fields, a.Tags)
+	testutil.AssertCheckWithMetrics(t, a.Check, 18, fields, a.Tags)
+
+	fields = map[string]float64{
+		"apache.scoreboard.waiting_for_connection": 630,
+		"apache.scoreboard.starting_up":            0,
+		"apache.scoreboard.reading_request":        157,
+		"apache.scoreboard.sending_reply":          113,
+		"apache.scoreboard.keepalive":              0,
+		"apache.scoreboard.dns_lookup":             0,
+		"apache.scoreboard.closing_connection":     0,
+		"apache.scoreboard.logging":                0,
+		"apache.scoreboard.gracefully_finishing":   0,
+		"apache.scoreboard.idle_cleanup":           0,
+		"apache.scoreboard.open_slot":              2850,
+		"apache.performance.busy_pct":              float64(270) / float64(3750) * 100,
+	}
+	testutil.AssertCheckWithMetrics(t, a.Check, 18, fields, a.Tags, 0.0001)
 
 	fields = map[string]float64{
 		"apache.net.bytes_per_s":   2000 * 1024,
 		"apache.net.request_per_s": 200,
 	}
-	testutil.AssertCheckWithRateMetrics(t, a.Check, a2.Check, 8, fields, a.Tags)
+	testutil.AssertCheckWithRateMetrics(t, a.Check, a2.Check, 20, fields, a.Tags)
+}
+
+func TestApacheTLSAndHeaders(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "status.example.com" || r.Header.Get("X-Auth-Token") != "secret" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprintln(w, apacheStatus)
+	}))
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	a := Apache{
+		ApacheStatusURL: fmt.Sprintf("%s/server-status?auto", ts.URL),
+		Headers: map[string]string{
+			"Host":         "status.example.com",
+			"X-Auth-Token": "secret",
+		},
+		Tags: []string{"service:apache"},
+	}
+	// The certificate of the test server is self-signed.
+	err := a.Check(testutil.MockAggregator(make(chan metric.Metric, 100)))
+	assert.Error(t, err)
+
+	a.InsecureSkipVerify = true
+	a.client = nil
+	fields := map[string]float64{
+		"apache.performance.busy_workers": 270,
+	}
+	testutil.AssertCheckWithMetrics(t, a.Check, 18, fields, a.Tags)
+
+	a.Headers = nil
+	err = a.Check(testutil.MockAggregator(make(chan metric.Metric, 100)))
+	assert.Error(t, err)
 }
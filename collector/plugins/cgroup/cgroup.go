@@ -0,0 +1,337 @@
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+	"github.com/cloudinsight/cloudinsight-agent/common/util"
+)
+
+// NewCGroup XXX
+func NewCGroup(conf plugin.InitConfig) plugin.Plugin {
+	return &CGroup{}
+}
+
+// CGroup collects the metrics of containers from the cgroup hierarchies
+// directly, without talking to the container runtime.
+type CGroup struct {
+	Root       string
+	Resolver   string
+	DockerRoot string `yaml:"docker_root"`
+	Tags       []string
+
+	resolver Resolver
+}
+
+const (
+	defaultRoot       = "/sys/fs/cgroup"
+	defaultResolver   = "id"
+	defaultDockerRoot = "/var/lib/docker"
+
+	// USER_HZ, the unit of cpuacct.stat
+	userHZ = 100
+
+	// The limits of cgroup v1 are set to the max value rounded to the page
+	// size when they are unlimited.
+	unlimited = 1 << 62
+)
+
+// stats stores the metrics of a container by type.
+type stats struct {
+	gauges map[string]interface{}
+	rates  map[string]interface{}
+	counts map[string]interface{}
+}
+
+func newStats() *stats {
+	return &stats{
+		gauges: make(map[string]interface{}),
+		rates:  make(map[string]interface{}),
+		counts: make(map[string]interface{}),
+	}
+}
+
+// Check XXX
+func (c *CGroup) Check(agg metric.Aggregator) error {
+	if c.resolver == nil {
+		name := c.Resolver
+		if name == "" {
+			name = defaultResolver
+		}
+		creator, ok := resolvers[name]
+		if !ok {
+			return fmt.Errorf("Unknown cgroup resolver: %s", name)
+		}
+		c.resolver = creator(c)
+	}
+
+	root := c.Root
+	if root == "" {
+		root = defaultRoot
+	}
+
+	v2 := isUnified(root)
+	base := root
+	if !v2 {
+		// Containers always have a memory cgroup, which is used to discover
+		// the containers in the other hierarchies.
+		base = filepath.Join(root, "memory")
+	}
+	base, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The cgroup has been removed while walking.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() || path == base {
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		container, ok := c.resolver.Resolve("/" + rel)
+		if !ok {
+			return nil
+		}
+
+		var s *stats
+		if v2 {
+			s = collectV2(path)
+		} else {
+			s = collectV1(root, rel)
+		}
+		c.submit(container, s, agg)
+
+		// Nested cgroups of a container are accounted to the container.
+		return filepath.SkipDir
+	})
+}
+
+func (c *CGroup) submit(container *Container, s *stats, agg metric.Aggregator) {
+	tags := make([]string, 0, len(c.Tags)+len(container.Tags)+2)
+	tags = append(tags, c.Tags...)
+	tags = append(tags, "container_id:"+container.ID, "container_name:"+container.Name)
+	tags = append(tags, container.Tags...)
+
+	agg.AddMetrics("gauge", "cgroup", s.gauges, tags, "")
+	agg.AddMetrics("rate", "cgroup", s.rates, tags, "")
+	agg.AddMetrics("monotoniccount", "cgroup", s.counts, tags, "")
+}
+
+// isUnified returns true if the cgroup v2 hierarchy is mounted at root.
+func isUnified(root string) bool {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
+
+func collectV1(root, path string) *stats {
+	s := newStats()
+	dir := func(controller string) string {
+		return filepath.Join(root, controller, path)
+	}
+
+	if v, ok := util.ReadValue(filepath.Join(dir("cpuacct"), "cpuacct.usage")); ok {
+		s.rates["cpu.usage"] = v
+	}
+	if kv, ok := readKeyValues(filepath.Join(dir("cpuacct"), "cpuacct.stat")); ok {
+		s.rates["cpu.user"] = kv["user"] * 1e9 / userHZ
+		s.rates["cpu.system"] = kv["system"] * 1e9 / userHZ
+	}
+	if kv, ok := readKeyValues(filepath.Join(dir("cpu"), "cpu.stat")); ok {
+		s.rates["cpu.periods"] = kv["nr_periods"]
+		s.rates["cpu.throttled"] = kv["nr_throttled"]
+		s.rates["cpu.throttled_time"] = kv["throttled_time"]
+	}
+
+	if v, ok := util.ReadValue(filepath.Join(dir("memory"), "memory.usage_in_bytes")); ok {
+		s.gauges["mem.usage"] = v
+		if limit, ok := util.ReadValue(filepath.Join(dir("memory"), "memory.limit_in_bytes")); ok && limit < unlimited {
+			s.gauges["mem.limit"] = limit
+			s.gauges["mem.in_use"] = v / limit
+		}
+	}
+	if kv, ok := readKeyValues(filepath.Join(dir("memory"), "memory.stat")); ok {
+		s.gauges["mem.rss"] = kv["rss"]
+		s.gauges["mem.cache"] = kv["cache"]
+		if swap, ok := kv["swap"]; ok {
+			s.gauges["mem.swap"] = swap
+		}
+	}
+	// oom_kill is only available since Linux 4.13
+	if kv, ok := readKeyValues(filepath.Join(dir("memory"), "memory.oom_control")); ok {
+		if v, ok := kv["oom_kill"]; ok {
+			s.counts["mem.oom_kills"] = v
+		}
+	}
+
+	if io, ok := readBlkio(filepath.Join(dir("blkio"), "blkio.throttle.io_service_bytes")); ok {
+		s.rates["io.read_bytes"] = io["Read"]
+		s.rates["io.write_bytes"] = io["Write"]
+	}
+	if io, ok := readBlkio(filepath.Join(dir("blkio"), "blkio.throttle.io_serviced")); ok {
+		s.rates["io.read_ops"] = io["Read"]
+		s.rates["io.write_ops"] = io["Write"]
+	}
+
+	collectPids(dir("pids"), s)
+	return s
+}
+
+func collectV2(dir string) *stats {
+	s := newStats()
+
+	if kv, ok := readKeyValues(filepath.Join(dir, "cpu.stat")); ok {
+		s.rates["cpu.usage"] = kv["usage_usec"] * 1000
+		s.rates["cpu.user"] = kv["user_usec"] * 1000
+		s.rates["cpu.system"] = kv["system_usec"] * 1000
+		// Only available when the cpu controller is enabled.
+		if _, ok := kv["nr_periods"]; ok {
+			s.rates["cpu.periods"] = kv["nr_periods"]
+			s.rates["cpu.throttled"] = kv["nr_throttled"]
+			s.rates["cpu.throttled_time"] = kv["throttled_usec"] * 1000
+		}
+	}
+
+	if v, ok := util.ReadValue(filepath.Join(dir, "memory.current")); ok {
+		s.gauges["mem.usage"] = v
+		// memory.max is "max" when unlimited
+		if limit, ok := util.ReadValue(filepath.Join(dir, "memory.max")); ok {
+			s.gauges["mem.limit"] = limit
+			s.gauges["mem.in_use"] = v / limit
+		}
+	}
+	if kv, ok := readKeyValues(filepath.Join(dir, "memory.stat")); ok {
+		s.gauges["mem.rss"] = kv["anon"]
+		s.gauges["mem.cache"] = kv["file"]
+	}
+	if v, ok := util.ReadValue(filepath.Join(dir, "memory.swap.current")); ok {
+		s.gauges["mem.swap"] = v
+	}
+	if kv, ok := readKeyValues(filepath.Join(dir, "memory.events")); ok {
+		s.counts["mem.oom_kills"] = kv["oom_kill"]
+	}
+
+	if io, ok := readIOStat(filepath.Join(dir, "io.stat")); ok {
+		s.rates["io.read_bytes"] = io["rbytes"]
+		s.rates["io.write_bytes"] = io["wbytes"]
+		s.rates["io.read_ops"] = io["rios"]
+		s.rates["io.write_ops"] = io["wios"]
+	}
+
+	collectPids(dir, s)
+	return s
+}
+
+func collectPids(dir string, s *stats) {
+	if v, ok := util.ReadValue(filepath.Join(dir, "pids.current")); ok {
+		s.gauges["pids.current"] = v
+	}
+	// pids.max is "max" when unlimited
+	if v, ok := util.ReadValue(filepath.Join(dir, "pids.max")); ok {
+		s.gauges["pids.limit"] = v
+	}
+}
+
+// readKeyValues reads a flat keyed file, e.g. cpu.stat
+// nr_periods 100
+// nr_throttled 3
+func readKeyValues(path string) (map[string]float64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	kv := make(map[string]float64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		kv[fields[0]] = v
+	}
+	return kv, true
+}
+
+// readBlkio sums the stats of all the devices by operation, e.g.
+// 8:0 Read 4096
+// 8:0 Write 8192
+// Total 12288
+func readBlkio(path string) (map[string]float64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	io := make(map[string]float64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			continue
+		}
+		io[fields[1]] += v
+	}
+	return io, true
+}
+
+// readIOStat sums the stats of all the devices by key, e.g.
+// 8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0
+func readIOStat(path string) (map[string]float64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	io := make(map[string]float64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			v, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				continue
+			}
+			io[parts[0]] += v
+		}
+	}
+	return io, true
+}
+
+func init() {
+	collector.Add("cgroup", NewCGroup)
+}
@@ -0,0 +1,192 @@
+package cgroup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+const (
+	id1 = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	id2 = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+func TestCGroupV1(t *testing.T) {
+	c := &CGroup{
+		Root: "testdata/v1",
+		Tags: []string{"env:test"},
+	}
+
+	fields := map[string]float64{
+		"cgroup.mem.usage":    104857600,
+		"cgroup.mem.limit":    209715200,
+		"cgroup.mem.in_use":   0.5,
+		"cgroup.mem.rss":      73400320,
+		"cgroup.mem.cache":    20971520,
+		"cgroup.mem.swap":     0,
+		"cgroup.pids.current": 12,
+	}
+	tags := []string{"env:test", "container_id:" + id1, "container_name:aaaaaaaaaaaa"}
+	testutil.AssertCheckWithMetrics(t, c.Check, 7, fields, tags)
+}
+
+func TestCGroupV1WithRates(t *testing.T) {
+	c := &CGroup{
+		Root: "testdata/v1",
+	}
+
+	fields := map[string]float64{
+		"cgroup.cpu.usage":     0,
+		"cgroup.cpu.user":      0,
+		"cgroup.cpu.periods":   0,
+		"cgroup.io.read_ops":   0,
+		"cgroup.mem.oom_kills": 0,
+	}
+	tags := []string{"container_id:" + id1, "container_name:aaaaaaaaaaaa"}
+	// 7 gauges, 10 rates and 1 monotonic count
+	testutil.AssertCheckWithRateMetrics(t, c.Check, c.Check, 18, fields, tags)
+}
+
+func TestCGroupV2(t *testing.T) {
+	c := &CGroup{
+		Root: "testdata/v2",
+	}
+
+	fields := map[string]float64{
+		"cgroup.mem.usage":    52428800,
+		"cgroup.mem.limit":    104857600,
+		"cgroup.mem.in_use":   0.5,
+		"cgroup.mem.rss":      31457280,
+		"cgroup.mem.cache":    10485760,
+		"cgroup.mem.swap":     0,
+		"cgroup.pids.current": 7,
+		"cgroup.pids.limit":   100,
+	}
+	tags := []string{"container_id:" + id2, "container_name:bbbbbbbbbbbb", "container_runtime:docker"}
+	testutil.AssertCheckWithMetrics(t, c.Check, 8, fields, tags)
+}
+
+func TestCollectV1(t *testing.T) {
+	s := collectV1("testdata/v1", "docker/"+id1)
+
+	assert.Equal(t, float64(5000000000), s.rates["cpu.usage"])
+	assert.Equal(t, float64(3000000000), s.rates["cpu.user"])
+	assert.Equal(t, float64(1000000000), s.rates["cpu.system"])
+	assert.Equal(t, float64(20), s.rates["cpu.throttled"])
+	assert.Equal(t, float64(1500000000), s.rates["cpu.throttled_time"])
+	assert.Equal(t, float64(5120), s.rates["io.read_bytes"])
+	assert.Equal(t, float64(8192), s.rates["io.write_bytes"])
+	assert.Equal(t, float64(5), s.rates["io.read_ops"])
+	assert.Equal(t, float64(8), s.rates["io.write_ops"])
+	assert.Equal(t, float64(2), s.counts["mem.oom_kills"])
+	assert.NotContains(t, s.gauges, "pids.limit")
+}
+
+func TestCollectV2(t *testing.T) {
+	s := collectV2("testdata/v2/system.slice/docker-" + id2 + ".scope")
+
+	assert.Equal(t, float64(5000000000), s.rates["cpu.usage"])
+	assert.Equal(t, float64(3000000000), s.rates["cpu.user"])
+	assert.Equal(t, float64(2000000000), s.rates["cpu.system"])
+	assert.Equal(t, float64(200), s.rates["cpu.periods"])
+	assert.Equal(t, float64(1500000000), s.rates["cpu.throttled_time"])
+	assert.Equal(t, float64(5120), s.rates["io.read_bytes"])
+	assert.Equal(t, float64(8192), s.rates["io.write_bytes"])
+	assert.Equal(t, float64(5), s.rates["io.read_ops"])
+	assert.Equal(t, float64(8), s.rates["io.write_ops"])
+	assert.Equal(t, float64(1), s.counts["mem.oom_kills"])
+}
+
+func TestCGroupUnlimited(t *testing.T) {
+	s := collectV1("testdata/v1", "system.slice/sshd.service")
+	assert.NotContains(t, s.gauges, "mem.limit")
+}
+
+func TestCGroupUnknownResolver(t *testing.T) {
+	c := &CGroup{
+		Root:     "testdata/v1",
+		Resolver: "unknown",
+	}
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	err := c.Check(testutil.MockAggregator(metricC))
+	assert.EqualError(t, err, "Unknown cgroup resolver: unknown")
+}
+
+func TestIDResolver(t *testing.T) {
+	r := idResolver{}
+	tests := []struct {
+		path    string
+		runtime string
+	}{
+		{"/docker/" + id1, ""},
+		{"/system.slice/docker-" + id1 + ".scope", "container_runtime:docker"},
+		{"/kubepods/burstable/pod1234/" + id1, ""},
+		{"/kubepods.slice/kubepods-pod1234.slice/cri-containerd-" + id1 + ".scope", "container_runtime:containerd"},
+		{"/kubepods.slice/kubepods-pod1234.slice/crio-" + id1 + ".scope", "container_runtime:crio"},
+		{"/machine.slice/libpod-" + id1 + ".scope", "container_runtime:podman"},
+	}
+	for _, test := range tests {
+		container, ok := r.Resolve(test.path)
+		require.True(t, ok, test.path)
+		assert.Equal(t, id1, container.ID)
+		assert.Equal(t, "aaaaaaaaaaaa", container.Name)
+		if test.runtime != "" {
+			assert.Equal(t, []string{test.runtime}, container.Tags)
+		} else {
+			assert.Empty(t, container.Tags)
+		}
+	}
+
+	for _, path := range []string{"/system.slice/sshd.service", "/docker", "/user.slice/user-1000.slice"} {
+		_, ok := r.Resolve(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestDockerResolver(t *testing.T) {
+	c := &CGroup{
+		Root:       "testdata/v1",
+		Resolver:   "docker",
+		DockerRoot: "testdata/docker",
+	}
+
+	fields := map[string]float64{
+		"cgroup.mem.usage": 104857600,
+	}
+	tags := []string{"container_id:" + id1, "container_name:web", "docker_image:nginx:latest"}
+	testutil.AssertCheckWithMetrics(t, c.Check, 7, fields, tags)
+
+	// Fall back to the short ID if the container isn't found.
+	r := resolvers["docker"](c)
+	container, ok := r.Resolve("/docker/" + id2)
+	require.True(t, ok)
+	assert.Equal(t, "bbbbbbbbbbbb", container.Name)
+}
+
+func TestAddResolver(t *testing.T) {
+	AddResolver("static", func(c *CGroup) Resolver {
+		return staticResolver{}
+	})
+	defer delete(resolvers, "static")
+
+	c := &CGroup{
+		Root:     "testdata/v2",
+		Resolver: "static",
+	}
+	tags := []string{"container_id:sshd", "container_name:sshd"}
+	testutil.AssertCheckWithMetrics(t, c.Check, 1, map[string]float64{"cgroup.pids.current": 2}, tags)
+}
+
+type staticResolver struct{}
+
+func (r staticResolver) Resolve(path string) (*Container, bool) {
+	if path != "/system.slice/sshd.service" {
+		return nil, false
+	}
+	return &Container{ID: "sshd", Name: "sshd"}, true
+}
@@ -0,0 +1,124 @@
+package cgroup
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Container is the container running in a cgroup.
+type Container struct {
+	ID   string
+	Name string
+	Tags []string
+}
+
+// Resolver maps the path of a cgroup, relative to the root of the hierarchy,
+// to the container running in it. Resolve returns false for the cgroups
+// which don't belong to a container, e.g. system.slice or user.slice.
+type Resolver interface {
+	Resolve(path string) (*Container, bool)
+}
+
+// ResolverCreator XXX
+type ResolverCreator func(c *CGroup) Resolver
+
+var resolvers = map[string]ResolverCreator{}
+
+// AddResolver registers a resolver, so that it can be selected with the
+// `resolver` option of the plugin.
+func AddResolver(name string, creator ResolverCreator) {
+	resolvers[name] = creator
+}
+
+// The directory of a container is named after its ID by all the common
+// runtimes, with a runtime specific prefix when the systemd cgroup driver
+// is used, e.g.
+// /docker/<id>
+// /system.slice/docker-<id>.scope
+// /kubepods/burstable/pod<uid>/<id>
+// /kubepods.slice/kubepods-pod<uid>.slice/cri-containerd-<id>.scope
+// /machine.slice/libpod-<id>.scope
+var containerIDPattern = regexp.MustCompile(`^(?:([a-z-]+)-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+var runtimes = map[string]string{
+	"docker":         "docker",
+	"cri-containerd": "containerd",
+	"crio":           "crio",
+	"libpod":         "podman",
+}
+
+// idResolver only extracts the container ID from the path, the name of the
+// container is the short ID.
+type idResolver struct{}
+
+func (r idResolver) Resolve(path string) (*Container, bool) {
+	m := containerIDPattern.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return nil, false
+	}
+	id := m[2]
+
+	container := &Container{
+		ID:   id,
+		Name: id[:12],
+	}
+	if runtime, ok := runtimes[m[1]]; ok {
+		container.Tags = append(container.Tags, "container_runtime:"+runtime)
+	}
+	return container, true
+}
+
+// dockerResolver reads the name and the image of the container from the
+// state of the Docker daemon on disk, so the daemon isn't involved.
+type dockerResolver struct {
+	idResolver
+	root string
+}
+
+type dockerConfig struct {
+	Name   string
+	Config struct {
+		Image string
+	}
+}
+
+func (r dockerResolver) Resolve(path string) (*Container, bool) {
+	container, ok := r.idResolver.Resolve(path)
+	if !ok {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(r.root, "containers", container.ID, "config.v2.json"))
+	if err != nil {
+		// Not a Docker container, or a container which just exited.
+		return container, true
+	}
+	conf := dockerConfig{}
+	if err = json.Unmarshal(data, &conf); err != nil {
+		return container, true
+	}
+
+	if conf.Name != "" {
+		container.Name = strings.TrimPrefix(conf.Name, "/")
+	}
+	if conf.Config.Image != "" {
+		container.Tags = append(container.Tags, "docker_image:"+conf.Config.Image)
+	}
+	return container, true
+}
+
+func init() {
+	AddResolver("id", func(c *CGroup) Resolver {
+		return idResolver{}
+	})
+	AddResolver("docker", func(c *CGroup) Resolver {
+		root := c.DockerRoot
+		if root == "" {
+			root = defaultDockerRoot
+		}
+		return dockerResolver{root: root}
+	})
+}
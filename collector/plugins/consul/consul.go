@@ -0,0 +1,312 @@
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+	"github.com/cloudinsight/cloudinsight-agent/common/util"
+)
+
+// NewConsul XXX
+func NewConsul(conf plugin.InitConfig) plugin.Plugin {
+	return &Consul{}
+}
+
+// Consul XXX
+type Consul struct {
+	URL               string
+	ACLToken          string   `yaml:"acl_token"`
+	CatalogChecks     bool     `yaml:"catalog_checks"`
+	CatalogLeaderOnly bool     `yaml:"catalog_leader_only"`
+	ServiceWhitelist  []string `yaml:"service_whitelist"`
+	CollectTelemetry  bool     `yaml:"collect_telemetry"`
+	Tags              []string
+
+	lastLeader string
+}
+
+// AgentSelf stores the information from /v1/agent/self
+type AgentSelf struct {
+	Member struct {
+		Name string
+		Addr string
+		Tags map[string]string
+	}
+}
+
+// HealthCheck stores a check from /v1/health/state/any
+type HealthCheck struct {
+	Node        string
+	CheckID     string
+	Status      string
+	ServiceName string
+}
+
+// Telemetry stores the information from /v1/agent/metrics
+type Telemetry struct {
+	Gauges []struct {
+		Name   string
+		Value  float64
+		Labels map[string]string
+	}
+	Counters []telemetrySample
+	Samples  []telemetrySample
+}
+
+type telemetrySample struct {
+	Name   string
+	Count  float64
+	Rate   float64
+	Min    float64
+	Max    float64
+	Mean   float64
+	Labels map[string]string
+}
+
+const defaultURL = "http://localhost:8500"
+
+var (
+	healthStatuses = []string{"passing", "warning", "critical"}
+)
+
+var tr = &http.Transport{
+	ResponseHeaderTimeout: time.Duration(3 * time.Second),
+}
+
+var client = &http.Client{
+	Transport: tr,
+	Timeout:   time.Duration(4 * time.Second),
+}
+
+// Check XXX
+func (c *Consul) Check(agg metric.Aggregator) error {
+	self := AgentSelf{}
+	err := c.get("/v1/agent/self", &self)
+	if err != nil {
+		return err
+	}
+	tags := c.getTags(self)
+
+	var leader string
+	err = c.get("/v1/status/leader", &leader)
+	if err != nil {
+		return err
+	}
+	c.checkLeaderChange(leader, tags, agg)
+
+	var peers []string
+	err = c.get("/v1/status/peers", &peers)
+	if err != nil {
+		return err
+	}
+	agg.Add("gauge", metric.NewMetric("consul.peers", len(peers), tags))
+
+	isLeader := isAgentLeader(self, leader)
+	var isLeaderValue float64
+	if isLeader {
+		isLeaderValue = 1
+	}
+	agg.Add("gauge", metric.NewMetric("consul.is_leader", isLeaderValue, tags))
+
+	if c.CatalogChecks {
+		if c.CatalogLeaderOnly && !isLeader {
+			log.Debugf("Consul agent %s is not the leader, skipping catalog metrics", self.Member.Name)
+		} else {
+			err = c.collectCatalog(tags, agg)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.CollectTelemetry {
+		err = c.collectTelemetry(tags, agg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Consul) collectCatalog(tags []string, agg metric.Aggregator) error {
+	services := make(map[string][]string)
+	err := c.get("/v1/catalog/services", &services)
+	if err != nil {
+		return err
+	}
+	agg.Add("gauge", metric.NewMetric("consul.catalog.services", len(services), tags))
+
+	var nodes []interface{}
+	err = c.get("/v1/catalog/nodes", &nodes)
+	if err != nil {
+		return err
+	}
+	agg.Add("gauge", metric.NewMetric("consul.catalog.nodes", len(nodes), tags))
+
+	var checks []HealthCheck
+	err = c.get("/v1/health/state/any", &checks)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]map[string]int)
+	for name := range services {
+		if c.isServiceIncluded(name) {
+			counts[name] = make(map[string]int)
+		}
+	}
+	for _, check := range checks {
+		if statuses, ok := counts[check.ServiceName]; ok {
+			statuses[check.Status]++
+		}
+	}
+
+	for name, statuses := range counts {
+		serviceTags := appendTags(tags, "consul_service:"+name)
+		for _, status := range healthStatuses {
+			agg.Add("gauge", metric.NewMetric("consul.health.checks."+status, statuses[status], serviceTags))
+		}
+	}
+	return nil
+}
+
+func (c *Consul) collectTelemetry(tags []string, agg metric.Aggregator) error {
+	telemetry := Telemetry{}
+	err := c.get("/v1/agent/metrics", &telemetry)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range telemetry.Gauges {
+		agg.Add("gauge", metric.NewMetric(normalize(g.Name), g.Value, labelsToTags(tags, g.Labels)))
+	}
+
+	for _, s := range telemetry.Counters {
+		agg.Add("gauge", metric.NewMetric(normalize(s.Name)+".rate", s.Rate, labelsToTags(tags, s.Labels)))
+	}
+
+	for _, s := range telemetry.Samples {
+		name := normalize(s.Name)
+		fields := map[string]interface{}{
+			"avg":   s.Mean,
+			"max":   s.Max,
+			"min":   s.Min,
+			"count": s.Count,
+		}
+		agg.AddMetrics("gauge", name, fields, labelsToTags(tags, s.Labels), "")
+	}
+	return nil
+}
+
+func (c *Consul) checkLeaderChange(leader string, tags []string, agg metric.Aggregator) {
+	lastLeader := c.lastLeader
+	c.lastLeader = leader
+	if lastLeader == "" || lastLeader == leader {
+		return
+	}
+
+	agg.AddEvent(metric.Event{
+		Title:          fmt.Sprintf("New Consul leader elected: %s", leader),
+		Text:           fmt.Sprintf("The Consul leader changed from %s to %s.", lastLeader, leader),
+		AlertType:      "info",
+		AggregationKey: "consul.new_leader",
+		SourceTypeName: "consul",
+		Tags:           appendTags(tags, "prev_consul_leader:"+lastLeader, "curr_consul_leader:"+leader),
+	})
+}
+
+func (c *Consul) isServiceIncluded(name string) bool {
+	if len(c.ServiceWhitelist) == 0 {
+		return true
+	}
+	return util.StringInSlice(name, c.ServiceWhitelist)
+}
+
+func (c *Consul) get(path string, v interface{}) error {
+	u := c.URL
+	if u == "" {
+		u = defaultURL
+	}
+	requestURI := strings.TrimSuffix(u, "/") + path
+
+	req, err := http.NewRequest("GET", requestURI, nil)
+	if err != nil {
+		return err
+	}
+	if c.ACLToken != "" {
+		req.Header.Set("X-Consul-Token", c.ACLToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making HTTP request to %s: %s", requestURI, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP status %s", requestURI, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *Consul) getTags(self AgentSelf) []string {
+	var tags []string
+	tags = append(tags, c.Tags...)
+	if dc, ok := self.Member.Tags["dc"]; ok {
+		tags = append(tags, "consul_datacenter:"+dc)
+	}
+	return tags
+}
+
+// isAgentLeader compares the address of the Consul server RPC of the agent
+// with the address of the leader, e.g. 10.0.0.1:8300
+func isAgentLeader(self AgentSelf, leader string) bool {
+	port, ok := self.Member.Tags["port"]
+	if !ok || self.Member.Addr == "" {
+		return false
+	}
+	return fmt.Sprintf("%s:%s", self.Member.Addr, port) == leader
+}
+
+// normalize prefixes the telemetry metric names with "consul." if needed.
+func normalize(name string) string {
+	if strings.HasPrefix(name, "consul.") {
+		return name
+	}
+	return "consul." + name
+}
+
+func labelsToTags(tags []string, labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	labelTags := make([]string, 0, len(keys))
+	for _, k := range keys {
+		labelTags = append(labelTags, k+":"+labels[k])
+	}
+	return appendTags(tags, labelTags...)
+}
+
+// appendTags returns a new slice, so that the tags shared by metrics are never
+// overwritten.
+func appendTags(tags []string, extra ...string) []string {
+	result := make([]string, 0, len(tags)+len(extra))
+	result = append(result, tags...)
+	return append(result, extra...)
+}
+
+func init() {
+	collector.Add("consul", NewConsul)
+}
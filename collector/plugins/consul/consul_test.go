@@ -0,0 +1,186 @@
+package consul
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+var (
+	agentSelf = `{
+  "Config": {"Datacenter": "dc1", "NodeName": "consul-1"},
+  "Member": {
+    "Name": "consul-1",
+    "Addr": "10.0.0.1",
+    "Port": 8301,
+    "Tags": {"dc": "dc1", "port": "8300", "role": "consul"}
+  }
+}`
+
+	peers = `["10.0.0.1:8300", "10.0.0.2:8300", "10.0.0.3:8300"]`
+
+	catalogServices = `{"consul": [], "redis": ["primary"], "web": []}`
+
+	catalogNodes = `[{"Node": "consul-1"}, {"Node": "consul-2"}, {"Node": "consul-3"}]`
+
+	healthChecks = `[
+  {"Node": "consul-1", "CheckID": "serfHealth", "Status": "passing", "ServiceName": ""},
+  {"Node": "consul-1", "CheckID": "service:redis", "Status": "passing", "ServiceName": "redis"},
+  {"Node": "consul-2", "CheckID": "service:redis", "Status": "critical", "ServiceName": "redis"},
+  {"Node": "consul-2", "CheckID": "service:web", "Status": "warning", "ServiceName": "web"}
+]`
+
+	agentMetrics = `{
+  "Timestamp": "2017-08-08 02:55:10 +0000 UTC",
+  "Gauges": [
+    {"Name": "consul.runtime.alloc_bytes", "Value": 4704008, "Labels": {}}
+  ],
+  "Points": [],
+  "Counters": [
+    {"Name": "consul.rpc.request", "Count": 4, "Rate": 0.4, "Sum": 4, "Min": 1, "Max": 1, "Mean": 1, "Stddev": 0, "Labels": {}}
+  ],
+  "Samples": [
+    {"Name": "consul.raft.commitTime", "Count": 2, "Rate": 0.2, "Sum": 3, "Min": 1, "Max": 2, "Mean": 1.5, "Stddev": 0.7, "Labels": {"method": "apply"}}
+  ]
+}`
+)
+
+func newServer(leader *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+
+		switch r.URL.Path {
+		case "/v1/agent/self":
+			rsp = agentSelf
+		case "/v1/status/leader":
+			rsp = fmt.Sprintf("%q", *leader)
+		case "/v1/status/peers":
+			rsp = peers
+		case "/v1/catalog/services":
+			rsp = catalogServices
+		case "/v1/catalog/nodes":
+			rsp = catalogNodes
+		case "/v1/health/state/any":
+			rsp = healthChecks
+		case "/v1/agent/metrics":
+			rsp = agentMetrics
+		default:
+			panic("Cannot handle request")
+		}
+		fmt.Fprintln(w, rsp)
+	}))
+}
+
+func TestConsulCheck(t *testing.T) {
+	leader := "10.0.0.1:8300"
+	ts := newServer(&leader)
+	defer ts.Close()
+
+	c := &Consul{
+		URL:              ts.URL,
+		CatalogChecks:    true,
+		CollectTelemetry: true,
+		Tags:             []string{"service:consul"},
+	}
+
+	fields := map[string]float64{
+		"consul.peers":               3,
+		"consul.is_leader":           1,
+		"consul.catalog.services":    3,
+		"consul.catalog.nodes":       3,
+		"consul.runtime.alloc_bytes": 4704008,
+		"consul.rpc.request.rate":    0.4,
+	}
+	tags := []string{"service:consul", "consul_datacenter:dc1"}
+	testutil.AssertCheckWithMetrics(t, c.Check, 19, fields, tags)
+
+	fields = map[string]float64{
+		"consul.health.checks.passing":  1,
+		"consul.health.checks.warning":  0,
+		"consul.health.checks.critical": 1,
+	}
+	tags = []string{"service:consul", "consul_datacenter:dc1", "consul_service:redis"}
+	testutil.AssertCheckWithMetrics(t, c.Check, 19, fields, tags)
+
+	fields = map[string]float64{
+		"consul.raft.commitTime.avg":   1.5,
+		"consul.raft.commitTime.max":   2,
+		"consul.raft.commitTime.min":   1,
+		"consul.raft.commitTime.count": 2,
+	}
+	tags = []string{"service:consul", "consul_datacenter:dc1", "method:apply"}
+	testutil.AssertCheckWithMetrics(t, c.Check, 19, fields, tags)
+}
+
+func TestConsulCheckNotLeader(t *testing.T) {
+	leader := "10.0.0.2:8300"
+	ts := newServer(&leader)
+	defer ts.Close()
+
+	c := &Consul{
+		URL:               ts.URL,
+		CatalogChecks:     true,
+		CatalogLeaderOnly: true,
+	}
+
+	fields := map[string]float64{
+		"consul.peers":     3,
+		"consul.is_leader": 0,
+	}
+	tags := []string{"consul_datacenter:dc1"}
+	testutil.AssertCheckWithMetrics(t, c.Check, 2, fields, tags)
+}
+
+func TestConsulLeaderChange(t *testing.T) {
+	leader := "10.0.0.1:8300"
+	ts := newServer(&leader)
+	defer ts.Close()
+
+	c := &Consul{
+		URL: ts.URL,
+	}
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	require.NoError(t, c.Check(agg))
+	leader = "10.0.0.2:8300"
+	require.NoError(t, c.Check(agg))
+	agg.Flush()
+
+	var events []metric.Event
+	for len(metricC) > 0 {
+		m := <-metricC
+		if e, ok := m.Value.(metric.Event); ok {
+			events = append(events, e)
+		}
+	}
+	require.Len(t, events, 1)
+	assert.Equal(t, "New Consul leader elected: 10.0.0.2:8300", events[0].Title)
+	assert.Equal(t, []string{
+		"consul_datacenter:dc1",
+		"prev_consul_leader:10.0.0.1:8300",
+		"curr_consul_leader:10.0.0.2:8300",
+	}, events[0].Tags)
+}
+
+func TestIsServiceIncluded(t *testing.T) {
+	c := &Consul{}
+	assert.True(t, c.isServiceIncluded("web"))
+
+	c.ServiceWhitelist = []string{"redis"}
+	assert.False(t, c.isServiceIncluded("web"))
+	assert.True(t, c.isServiceIncluded("redis"))
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "consul.runtime.alloc_bytes", normalize("consul.runtime.alloc_bytes"))
+	assert.Equal(t, "consul.memberlist.gossip", normalize("memberlist.gossip"))
+}
@@ -0,0 +1,222 @@
+package directory
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+)
+
+// NewDirectory XXX
+func NewDirectory(conf plugin.InitConfig) plugin.Plugin {
+	return &Directory{}
+}
+
+// Directory XXX
+type Directory struct {
+	Directory      string
+	Name           string
+	Pattern        string
+	Recursive      bool
+	MaxDepth       int  `yaml:"max_depth"`
+	FollowSymlinks bool `yaml:"follow_symlinks"`
+	FileGauges     bool `yaml:"file_gauges"`
+	MaxFiles       int  `yaml:"max_files"`
+	Tags           []string
+}
+
+const (
+	defaultMaxFiles = 10000
+
+	// The number of entries read from a directory at once.
+	readBatchSize = 1000
+)
+
+type stats struct {
+	files   int
+	bytes   int64
+	newest  time.Time
+	oldest  time.Time
+	scanned int
+}
+
+// errTooManyFiles stops the walk when max_files is reached.
+var errTooManyFiles = fmt.Errorf("too many files")
+
+// Check XXX
+func (d *Directory) Check(agg metric.Aggregator) error {
+	if d.Directory == "" {
+		return fmt.Errorf("directory is required")
+	}
+	if _, err := filepath.Match(d.Pattern, ""); err != nil {
+		return fmt.Errorf("Invalid pattern %s: %s", d.Pattern, err)
+	}
+
+	info, err := os.Stat(d.Directory)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", d.Directory)
+	}
+
+	name := d.Name
+	if name == "" {
+		name = d.Directory
+	}
+	tags := make([]string, 0, len(d.Tags)+1)
+	tags = append(tags, d.Tags...)
+	tags = append(tags, "dir_name:"+name)
+
+	now := time.Now()
+	s := &stats{}
+	visited := make(map[string]bool)
+	err = d.walk(d.Directory, 0, now, s, visited, tags, agg)
+	if err == errTooManyFiles {
+		log.Warnf("Stopped scanning %s after %d files, the metrics are incomplete.", d.Directory, d.maxFiles())
+	} else if err != nil {
+		return err
+	}
+
+	agg.Add("gauge", metric.NewMetric("directory.files", s.files, tags))
+	agg.Add("gauge", metric.NewMetric("directory.bytes", s.bytes, tags))
+	if s.files > 0 {
+		agg.Add("gauge", metric.NewMetric("directory.age.newest", now.Sub(s.newest).Seconds(), tags))
+		agg.Add("gauge", metric.NewMetric("directory.age.oldest", now.Sub(s.oldest).Seconds(), tags))
+	}
+	return nil
+}
+
+func (d *Directory) walk(
+	dir string,
+	depth int,
+	now time.Time,
+	s *stats,
+	visited map[string]bool,
+	tags []string,
+	agg metric.Aggregator,
+) error {
+	// Avoid the loops of symlinks pointing to the parent directories.
+	if realPath, err := filepath.EvalSymlinks(dir); err == nil {
+		if visited[realPath] {
+			return nil
+		}
+		visited[realPath] = true
+	}
+
+	// Read the entries in batches instead of ioutil.ReadDir, which reads and
+	// sorts the whole directory before max_files can stop the walk.
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	var subdirs []string
+	for {
+		entries, readErr := f.Readdir(readBatchSize)
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+
+			if entry.Mode()&os.ModeSymlink != 0 {
+				if !d.FollowSymlinks {
+					continue
+				}
+				entry, err = os.Stat(path)
+				if err != nil {
+					// A dangling symlink
+					log.Debugf("Failed to follow symlink %s: %s", path, err)
+					continue
+				}
+			}
+
+			if entry.IsDir() {
+				if d.Recursive && (d.MaxDepth <= 0 || depth < d.MaxDepth) {
+					subdirs = append(subdirs, path)
+				}
+				continue
+			}
+
+			if s.scanned >= d.maxFiles() {
+				f.Close()
+				return errTooManyFiles
+			}
+			s.scanned++
+			d.add(entry, path, now, s, tags, agg)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			f.Close()
+			return readErr
+		}
+	}
+	// Close the directory before descending, to keep a single file open.
+	f.Close()
+
+	for _, path := range subdirs {
+		err := d.walk(path, depth+1, now, s, visited, tags, agg)
+		if err == errTooManyFiles {
+			return err
+		}
+		if err != nil {
+			log.Warnf("Failed to read directory %s: %s", path, err)
+		}
+	}
+	return nil
+}
+
+func (d *Directory) add(
+	entry os.FileInfo,
+	path string,
+	now time.Time,
+	s *stats,
+	tags []string,
+	agg metric.Aggregator,
+) {
+	if !d.match(entry.Name()) {
+		return
+	}
+
+	s.files++
+	s.bytes += entry.Size()
+	mtime := entry.ModTime()
+	if s.newest.IsZero() || mtime.After(s.newest) {
+		s.newest = mtime
+	}
+	if s.oldest.IsZero() || mtime.Before(s.oldest) {
+		s.oldest = mtime
+	}
+
+	if d.FileGauges {
+		fileTags := make([]string, 0, len(tags)+1)
+		fileTags = append(fileTags, tags...)
+		fileTags = append(fileTags, "filename:"+path)
+		agg.Add("gauge", metric.NewMetric("directory.file.bytes", entry.Size(), fileTags))
+		agg.Add("gauge", metric.NewMetric("directory.file.age", now.Sub(mtime).Seconds(), fileTags))
+	}
+}
+
+func (d *Directory) match(name string) bool {
+	if d.Pattern == "" {
+		return true
+	}
+	ok, err := filepath.Match(d.Pattern, name)
+	return err == nil && ok
+}
+
+func (d *Directory) maxFiles() int {
+	if d.MaxFiles > 0 {
+		return d.MaxFiles
+	}
+	return defaultMaxFiles
+}
+
+func init() {
+	collector.Add("directory", NewDirectory)
+}
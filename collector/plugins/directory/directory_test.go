@@ -0,0 +1,208 @@
+package directory
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+// newTree creates the directory tree:
+// a.log          10 bytes, 1 hour old
+// b.tar.gz       20 bytes, 2 hours old
+// sub/c.log      30 bytes, 3 hours old
+// sub/deep/d.log 40 bytes, 4 hours old
+// link -> sub
+func newTree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "directory")
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub", "deep"), 0755))
+	files := []struct {
+		path  string
+		size  int
+		hours int
+	}{
+		{"a.log", 10, 1},
+		{"b.tar.gz", 20, 2},
+		{"sub/c.log", 30, 3},
+		{"sub/deep/d.log", 40, 4},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		require.NoError(t, ioutil.WriteFile(path, make([]byte, f.size), 0644))
+		mtime := time.Now().Add(-time.Duration(f.hours) * time.Hour)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(dir, "sub"), filepath.Join(dir, "link")))
+	return dir
+}
+
+func TestDirectoryCheck(t *testing.T) {
+	dir := newTree(t)
+	defer os.RemoveAll(dir)
+
+	d := &Directory{
+		Directory: dir,
+		Name:      "spool",
+		Tags:      []string{"env:test"},
+	}
+	fields := map[string]float64{
+		"directory.files":      2,
+		"directory.bytes":      30,
+		"directory.age.newest": 3600,
+		"directory.age.oldest": 7200,
+	}
+	tags := []string{"env:test", "dir_name:spool"}
+	testutil.AssertCheckWithMetrics(t, d.Check, 4, fields, tags, 5)
+}
+
+func TestDirectoryRecursive(t *testing.T) {
+	dir := newTree(t)
+	defer os.RemoveAll(dir)
+
+	d := &Directory{
+		Directory: dir,
+		Pattern:   "*.log",
+		Recursive: true,
+	}
+	fields := map[string]float64{
+		"directory.files":      3,
+		"directory.bytes":      80,
+		"directory.age.oldest": 14400,
+	}
+	tags := []string{"dir_name:" + dir}
+	testutil.AssertCheckWithMetrics(t, d.Check, 4, fields, tags, 5)
+
+	d.MaxDepth = 1
+	fields = map[string]float64{
+		"directory.files": 2,
+		"directory.bytes": 40,
+	}
+	testutil.AssertCheckWithMetrics(t, d.Check, 4, fields, tags)
+}
+
+func TestDirectoryFollowSymlinks(t *testing.T) {
+	dir := newTree(t)
+	defer os.RemoveAll(dir)
+
+	// The files under link are the same as the ones under sub, so they are
+	// only counted once.
+	d := &Directory{
+		Directory:      dir,
+		Recursive:      true,
+		FollowSymlinks: true,
+	}
+	fields := map[string]float64{
+		"directory.files": 4,
+		"directory.bytes": 100,
+	}
+	tags := []string{"dir_name:" + dir}
+	testutil.AssertCheckWithMetrics(t, d.Check, 4, fields, tags)
+
+	require.NoError(t, os.Symlink(filepath.Join(dir, "a.log"), filepath.Join(dir, "sub", "a.link")))
+	fields = map[string]float64{
+		"directory.files": 5,
+		"directory.bytes": 110,
+	}
+	testutil.AssertCheckWithMetrics(t, d.Check, 4, fields, tags)
+
+	d.FollowSymlinks = false
+	fields = map[string]float64{
+		"directory.files": 4,
+		"directory.bytes": 100,
+	}
+	testutil.AssertCheckWithMetrics(t, d.Check, 4, fields, tags)
+}
+
+func TestDirectoryFileGauges(t *testing.T) {
+	dir := newTree(t)
+	defer os.RemoveAll(dir)
+
+	d := &Directory{
+		Directory:  dir,
+		Pattern:    "*.log",
+		FileGauges: true,
+	}
+	fields := map[string]float64{
+		"directory.file.bytes": 10,
+		"directory.file.age":   3600,
+	}
+	tags := []string{"dir_name:" + dir, "filename:" + filepath.Join(dir, "a.log")}
+	testutil.AssertCheckWithMetrics(t, d.Check, 6, fields, tags, 5)
+}
+
+func TestDirectoryMaxFiles(t *testing.T) {
+	dir := newTree(t)
+	defer os.RemoveAll(dir)
+
+	d := &Directory{
+		Directory: dir,
+		Recursive: true,
+		MaxFiles:  3,
+	}
+	fields := map[string]float64{
+		"directory.files": 3,
+	}
+	tags := []string{"dir_name:" + dir}
+	testutil.AssertCheckWithMetrics(t, d.Check, 4, fields, tags)
+}
+
+func TestDirectoryMaxFilesAcrossBatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "directory")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for i := 0; i < readBatchSize*2+500; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%d.log", i))
+		require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+	}
+
+	d := &Directory{
+		Directory: dir,
+		MaxFiles:  readBatchSize + 500,
+	}
+	fields := map[string]float64{
+		"directory.files": float64(readBatchSize + 500),
+	}
+	tags := []string{"dir_name:" + dir}
+	testutil.AssertCheckWithMetrics(t, d.Check, 4, fields, tags)
+}
+
+func TestDirectoryEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "directory")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	d := &Directory{
+		Directory: dir,
+	}
+	fields := map[string]float64{
+		"directory.files": 0,
+		"directory.bytes": 0,
+	}
+	tags := []string{"dir_name:" + dir}
+	testutil.AssertCheckWithMetrics(t, d.Check, 2, fields, tags)
+}
+
+func TestDirectoryInvalidConfig(t *testing.T) {
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	d := &Directory{}
+	assert.EqualError(t, d.Check(agg), "directory is required")
+
+	d = &Directory{Directory: "/nonexistent"}
+	assert.Error(t, d.Check(agg))
+
+	d = &Directory{Directory: os.TempDir(), Pattern: "["}
+	assert.Error(t, d.Check(agg))
+}
@@ -0,0 +1,231 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/sockets"
+)
+
+// dfAPIVersion is the highest version of the API whose /system/df is decoded
+// here, BuilderSize is removed since 1.42.
+const dfAPIVersion = "1.41"
+
+// The default interval in seconds of the disk usage collection, which is
+// expensive for the daemon as it walks the layers and volumes.
+const defaultDiskUsageInterval = 300
+
+// diskUsage is the response of /system/df, with the build cache which the
+// vendored client doesn't decode.
+type diskUsage struct {
+	types.DiskUsage
+	// The size of the build cache before API 1.39, still set until 1.42.
+	BuilderSize *int64
+	// The records of the build cache since API 1.39.
+	BuildCache []*buildCacheRecord
+}
+
+type buildCacheRecord struct {
+	ID     string
+	Size   int64
+	InUse  bool
+	Shared bool
+}
+
+// dfClient requests /system/df of the daemon directly, as the API version
+// pinned by the vendored client predates the build cache. The version is
+// negotiated with the daemon like the docker client does.
+type dfClient struct {
+	client  *http.Client
+	baseURL string
+	host    string
+	version string
+}
+
+func newDFClient(host string) (*dfClient, error) {
+	proto, addr, basePath, err := client.ParseHost(host)
+	if err != nil {
+		return nil, err
+	}
+	transport := new(http.Transport)
+	if err := sockets.ConfigureTransport(transport, proto, addr); err != nil {
+		return nil, err
+	}
+
+	c := &dfClient{
+		client:  &http.Client{Transport: transport},
+		baseURL: "http://" + addr + basePath,
+	}
+	if proto == "unix" || proto == "npipe" {
+		// The host doesn't matter for the local sockets, like the client.
+		c.baseURL = "http://docker" + basePath
+		c.host = "docker"
+	}
+	return c, nil
+}
+
+func (c *dfClient) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.host != "" {
+		req.Host = c.host
+	}
+	return c.client.Do(req.WithContext(ctx))
+}
+
+// negotiateVersion uses the version of the daemon if it's older than
+// dfAPIVersion, the daemons which don't report it support 1.24.
+func (c *dfClient) negotiateVersion(ctx context.Context) error {
+	resp, err := c.get(ctx, "/_ping")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	version := resp.Header.Get("API-Version")
+	if version == "" {
+		version = "1.24"
+	}
+	if versions.LessThan(dfAPIVersion, version) {
+		version = dfAPIVersion
+	}
+	c.version = version
+	return nil
+}
+
+func (c *dfClient) DiskUsage(ctx context.Context) (diskUsage, error) {
+	var du diskUsage
+	if c.version == "" {
+		if err := c.negotiateVersion(ctx); err != nil {
+			return du, err
+		}
+	}
+
+	resp, err := c.get(ctx, "/v"+c.version+"/system/df")
+	if err != nil {
+		return du, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return du, fmt.Errorf("Error retrieving disk usage: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&du); err != nil {
+		return du, fmt.Errorf("Error retrieving disk usage: %v", err)
+	}
+	return du, nil
+}
+
+// collectDiskUsage reports the disk usage of `docker system df`, at most once
+// every disk_usage_interval seconds.
+func (d *Docker) collectDiskUsage(agg metric.Aggregator) error {
+	interval := time.Duration(d.DiskUsageInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultDiskUsageInterval * time.Second
+	}
+	now := time.Now()
+	if !d.lastDiskUsageTime.IsZero() && now.Sub(d.lastDiskUsageTime) < interval {
+		return nil
+	}
+	d.lastDiskUsageTime = now
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.timeout)*time.Second)
+	defer cancel()
+	if d.dfClient == nil && !d.testing {
+		c, err := newDFClient(d.host())
+		if err != nil {
+			return err
+		}
+		d.dfClient = c
+	}
+	du, err := diskUsageWrapper(d.dfClient, ctx)
+	if err != nil {
+		return err
+	}
+
+	// The images share their layers, the used size is the size which isn't
+	// shared by the images in use, like the docker CLI computes it.
+	// VirtualSize is the same as Size, and is removed since API 1.44.
+	var imagesUsed int64
+	for _, image := range du.Images {
+		size := image.Size
+		if size == 0 {
+			size = image.VirtualSize
+		}
+		if image.Containers > 0 && size != -1 && image.SharedSize != -1 {
+			imagesUsed += size - image.SharedSize
+		}
+	}
+	imagesReclaimable := du.LayersSize - imagesUsed
+	if imagesReclaimable < 0 {
+		imagesReclaimable = 0
+	}
+
+	var containersSize, containersReclaimable int64
+	for _, container := range du.Containers {
+		containersSize += container.SizeRw
+		if container.State != "running" {
+			containersReclaimable += container.SizeRw
+		}
+	}
+
+	var volumesSize, volumesReclaimable int64
+	for _, volume := range du.Volumes {
+		if volume.UsageData == nil || volume.UsageData.Size == -1 {
+			continue
+		}
+		volumesSize += volume.UsageData.Size
+		if volume.UsageData.RefCount == 0 {
+			volumesReclaimable += volume.UsageData.Size
+		}
+
+		tags := make([]string, 0, len(d.Tags)+2)
+		tags = append(tags, d.Tags...)
+		tags = append(tags, "volume_name:"+volume.Name, "volume_driver:"+volume.Driver)
+		agg.Add("gauge", metric.NewMetric("docker.volume.size", volume.UsageData.Size, tags))
+		agg.Add("gauge", metric.NewMetric("docker.volume.ref_count", volume.UsageData.RefCount, tags))
+	}
+
+	fields := map[string]interface{}{
+		"images.count":           len(du.Images),
+		"images.size":            du.LayersSize,
+		"images.reclaimable":     imagesReclaimable,
+		"containers.count":       len(du.Containers),
+		"containers.size":        containersSize,
+		"containers.reclaimable": containersReclaimable,
+		"volumes.count":          len(du.Volumes),
+		"volumes.size":           volumesSize,
+		"volumes.reclaimable":    volumesReclaimable,
+	}
+	// The build cache is only reported since API 1.31.
+	if du.BuildCache != nil || du.BuilderSize != nil {
+		var buildCacheSize, buildCacheInUse int64
+		if du.BuildCache != nil {
+			for _, record := range du.BuildCache {
+				if record.Shared {
+					continue
+				}
+				buildCacheSize += record.Size
+				if record.InUse {
+					buildCacheInUse += record.Size
+				}
+			}
+		} else {
+			buildCacheSize = *du.BuilderSize
+		}
+		fields["build_cache.count"] = len(du.BuildCache)
+		fields["build_cache.size"] = buildCacheSize
+		fields["build_cache.reclaimable"] = buildCacheSize - buildCacheInUse
+	}
+
+	agg.AddMetrics("gauge", "docker.disk", fields, d.Tags, "")
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
 	"github.com/cloudinsight/cloudinsight-agent/common/util"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/client"
 )
 
@@ -72,6 +73,9 @@ type Docker struct {
 	CollectContainerSize bool `yaml:"collect_container_size"`
 	CollectImagesStats   bool `yaml:"collect_images_stats"`
 	CollectImageSize     bool `yaml:"collect_image_size"`
+	CollectEvents        bool `yaml:"collect_events"`
+	CollectDiskUsage     bool `yaml:"collect_disk_usage"`
+	DiskUsageInterval    int  `yaml:"disk_usage_interval"`
 
 	Exclude []string
 	Include []string
@@ -83,7 +87,8 @@ type Docker struct {
 
 	timeout int64
 
-	client *client.Client
+	client   *client.Client
+	dfClient *dfClient
 
 	testing  bool
 	tagNames map[string][]string
@@ -92,6 +97,16 @@ type Docker struct {
 	excludePatterns    map[string]bool
 	includePatterns    map[string]bool
 	filteredContainers map[string]bool
+
+	lastEventTime     time.Time
+	lastDiskUsageTime time.Time
+}
+
+func (d *Docker) host() string {
+	if d.URL == "" {
+		return "unix:///var/run/docker.sock"
+	}
+	return d.URL
 }
 
 // Check XXX
@@ -100,16 +115,9 @@ func (d *Docker) Check(agg metric.Aggregator) error {
 		var c *client.Client
 		var err error
 		defaultHeaders := map[string]string{"User-Agent": "engine-api-cli-1.0"}
-		if d.URL == "" {
-			c, err = client.NewClient("unix:///var/run/docker.sock", "", nil, defaultHeaders)
-			if err != nil {
-				return err
-			}
-		} else {
-			c, err = client.NewClient(d.URL, "", nil, defaultHeaders)
-			if err != nil {
-				return err
-			}
+		c, err = client.NewClient(d.host(), "", nil, defaultHeaders)
+		if err != nil {
+			return err
 		}
 		d.client = c
 	}
@@ -165,6 +173,20 @@ func (d *Docker) Check(agg metric.Aggregator) error {
 		}
 	}
 
+	if d.CollectDiskUsage {
+		err := d.collectDiskUsage(agg)
+		if err != nil {
+			log.Errorf("Error collecting disk usage: %s", err.Error())
+		}
+	}
+
+	if d.CollectEvents {
+		err := d.collectEvents(agg)
+		if err != nil {
+			log.Errorf("Error collecting events: %s", err.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -210,6 +232,13 @@ func (d *Docker) collectContainer(
 
 	if isRunning {
 		collectContainerStats(v, agg, performanceTags, container.ID)
+
+		// Only the running containers are inspected, to save an API call
+		// per stopped container on every check.
+		err = d.collectContainerInspect(container, agg, performanceTags)
+		if err != nil {
+			log.Warnf("Error inspecting container %s: %s", container.ID, err)
+		}
 	}
 
 	if d.CollectContainerSize {
@@ -255,11 +284,14 @@ func collectContainerStats(
 	cpufields := map[string]interface{}{
 		"user":   stat.CPUStats.CPUUsage.UsageInUsermode,
 		"system": stat.CPUStats.CPUUsage.UsageInKernelmode,
+		// The throttled time is in nanoseconds.
+		"throttled":      stat.CPUStats.ThrottlingData.ThrottledPeriods,
+		"throttled_time": stat.CPUStats.ThrottlingData.ThrottledTime,
 	}
 	agg.AddMetrics("rate", "docker.cpu", cpufields, tags, "", now)
 
 	blkioStats := stat.BlkioStats
-	var readBytes, writeBytes uint64
+	var readBytes, writeBytes, readOps, writeOps uint64
 	for _, entry := range blkioStats.IoServiceBytesRecursive {
 		if entry.Op == "Read" {
 			readBytes = entry.Value
@@ -268,11 +300,109 @@ func collectContainerStats(
 			writeBytes = entry.Value
 		}
 	}
+	for _, entry := range blkioStats.IoServicedRecursive {
+		if entry.Op == "Read" {
+			readOps = entry.Value
+		}
+		if entry.Op == "Write" {
+			writeOps = entry.Value
+		}
+	}
 	blkiofields := map[string]interface{}{
 		"read_bytes":  readBytes,
 		"write_bytes": writeBytes,
+		"read_ops":    readOps,
+		"write_ops":   writeOps,
 	}
 	agg.AddMetrics("rate", "docker.io", blkiofields, tags, "", now)
+
+	for iface, netStats := range stat.Networks {
+		netTags := make([]string, 0, len(tags)+1)
+		netTags = append(netTags, tags...)
+		netTags = append(netTags, "interface:"+iface)
+		netfields := map[string]interface{}{
+			"bytes_rcvd":   netStats.RxBytes,
+			"bytes_sent":   netStats.TxBytes,
+			"packets_rcvd": netStats.RxPackets,
+			"packets_sent": netStats.TxPackets,
+			"errors_rcvd":  netStats.RxErrors,
+			"errors_sent":  netStats.TxErrors,
+			"drops_rcvd":   netStats.RxDropped,
+			"drops_sent":   netStats.TxDropped,
+		}
+		agg.AddMetrics("rate", "docker.net", netfields, netTags, "", now)
+	}
+
+	// The pids cgroup isn't always available.
+	if stat.PidsStats.Current > 0 {
+		agg.Add("gauge", metric.NewMetric("docker.pids.current", stat.PidsStats.Current, tags))
+		if stat.PidsStats.Limit > 0 {
+			agg.Add("gauge", metric.NewMetric("docker.pids.limit", stat.PidsStats.Limit, tags))
+		}
+	}
+}
+
+// collectContainerInspect collects the restart count, the CPU limits and the
+// HEALTHCHECK status, which are only available by inspecting the container.
+func (d *Docker) collectContainerInspect(
+	container types.Container,
+	agg metric.Aggregator,
+	tags []string,
+) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.timeout)*time.Second)
+	defer cancel()
+	info, err := inspectWrapper(d.client, ctx, container.ID)
+	if err != nil {
+		return err
+	}
+	if info.ContainerJSONBase == nil {
+		return nil
+	}
+
+	agg.Add("gauge", metric.NewMetric("docker.container.restarts", info.RestartCount, tags))
+
+	if hostConfig := info.HostConfig; hostConfig != nil {
+		if hostConfig.CPUShares > 0 {
+			agg.Add("gauge", metric.NewMetric("docker.cpu.shares", hostConfig.CPUShares, tags))
+		}
+		// The CPU limit in cores, set either by --cpus or by --cpu-quota.
+		var cpuLimit float64
+		if hostConfig.NanoCPUs > 0 {
+			cpuLimit = float64(hostConfig.NanoCPUs) / 1e9
+		} else if hostConfig.CPUQuota > 0 {
+			period := hostConfig.CPUPeriod
+			if period <= 0 {
+				// The default CFS period is 100ms.
+				period = 100000
+			}
+			cpuLimit = float64(hostConfig.CPUQuota) / float64(period)
+		}
+		if cpuLimit > 0 {
+			agg.Add("gauge", metric.NewMetric("docker.cpu.limit", cpuLimit, tags))
+		}
+	}
+
+	// A stopped container keeps the health status of its last run.
+	if info.State != nil && info.State.Running && info.State.Health != nil {
+		health := info.State.Health
+		var status int
+		var msg string
+		switch health.Status {
+		case types.Healthy:
+			status = metric.StatusOK
+		case types.Unhealthy:
+			status = metric.StatusCritical
+			if len(health.Log) > 0 && health.Log[len(health.Log)-1] != nil {
+				msg = strings.TrimSpace(health.Log[len(health.Log)-1].Output)
+			}
+		default:
+			status = metric.StatusUnknown
+			msg = "Container health is " + health.Status
+		}
+		agg.AddServiceCheck(metric.NewServiceCheck("docker.container_health", status, msg, tags))
+	}
+
+	return nil
 }
 
 func (d *Docker) collectImageStats(agg metric.Aggregator) error {
@@ -519,6 +649,44 @@ func statsWrapper(
 	return fc.ContainerStats(ctx, containerID, stream)
 }
 
+// inspectWrapper wraps client.Client.ContainerInspect for testing.
+func inspectWrapper(
+	c *client.Client,
+	ctx context.Context,
+	containerID string,
+) (types.ContainerJSON, error) {
+	if c != nil {
+		return c.ContainerInspect(ctx, containerID)
+	}
+	fc := FakeDockerClient{}
+	return fc.ContainerInspect(ctx, containerID)
+}
+
+// diskUsageWrapper wraps dfClient.DiskUsage for testing.
+func diskUsageWrapper(
+	c *dfClient,
+	ctx context.Context,
+) (diskUsage, error) {
+	if c != nil {
+		return c.DiskUsage(ctx)
+	}
+	fc := FakeDockerClient{}
+	return fc.DiskUsage(ctx)
+}
+
+// eventsWrapper wraps client.Client.Events for testing.
+func eventsWrapper(
+	c *client.Client,
+	ctx context.Context,
+	options types.EventsOptions,
+) (<-chan events.Message, <-chan error) {
+	if c != nil {
+		return c.Events(ctx, options)
+	}
+	fc := FakeDockerClient{}
+	return fc.Events(ctx, options)
+}
+
 func isContainerRunning(
 	container types.Container,
 ) bool {
@@ -1,9 +1,19 @@
 package docker
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDockerCheck(t *testing.T) {
@@ -35,7 +45,7 @@ func TestDockerCheck(t *testing.T) {
 		"image_name:quay.io:4443/coreos/etcd",
 		"image_tag:v2.2.2",
 	}
-	testutil.AssertCheckWithMetrics(t, d.Check, 17, fields, tags)
+	testutil.AssertCheckWithMetrics(t, d.Check, 23, fields, tags)
 
 	tags = []string{
 		"service:docker",
@@ -44,7 +54,7 @@ func TestDockerCheck(t *testing.T) {
 		"image_name:quay.io/coreos/etcd",
 		"image_tag:v2.2.2",
 	}
-	testutil.AssertCheckWithMetrics(t, d.Check, 17, fields, tags)
+	testutil.AssertCheckWithMetrics(t, d.Check, 23, fields, tags)
 
 	// Container performance stats
 	fields = map[string]float64{
@@ -66,15 +76,42 @@ func TestDockerCheck(t *testing.T) {
 		"image_name:quay.io:4443/coreos/etcd",
 		"image_tag:v2.2.2",
 	}
-	testutil.AssertCheckWithMetrics(t, d.Check, 17, fields, performanceTags)
+	testutil.AssertCheckWithMetrics(t, d.Check, 23, fields, performanceTags)
 
 	fields = map[string]float64{
-		"docker.cpu.system":     0,
-		"docker.cpu.user":       0,
-		"docker.io.read_bytes":  0,
-		"docker.io.write_bytes": 0,
+		"docker.cpu.system":         0,
+		"docker.cpu.user":           0,
+		"docker.io.read_bytes":      0,
+		"docker.io.write_bytes":     0,
+		"docker.io.read_ops":        0,
+		"docker.io.write_ops":       0,
+		"docker.cpu.throttled":      0,
+		"docker.cpu.throttled_time": 0,
 	}
-	testutil.AssertCheckWithRateMetrics(t, d.Check, d2.Check, 21, fields, performanceTags)
+	testutil.AssertCheckWithRateMetrics(t, d.Check, d2.Check, 40, fields, performanceTags)
+
+	// Container limits and inspect data
+	fields = map[string]float64{
+		"docker.pids.current":       12,
+		"docker.pids.limit":         100,
+		"docker.container.restarts": 2,
+		"docker.cpu.shares":         512,
+		"docker.cpu.limit":          1.5,
+	}
+	testutil.AssertCheckWithMetrics(t, d.Check, 23, fields, performanceTags)
+
+	netTags := append(append([]string{}, performanceTags...), "interface:eth0")
+	fields = map[string]float64{
+		"docker.net.bytes_rcvd":   0,
+		"docker.net.bytes_sent":   0,
+		"docker.net.packets_rcvd": 0,
+		"docker.net.packets_sent": 0,
+		"docker.net.errors_rcvd":  0,
+		"docker.net.errors_sent":  0,
+		"docker.net.drops_rcvd":   0,
+		"docker.net.drops_sent":   0,
+	}
+	testutil.AssertCheckWithRateMetrics(t, d.Check, d2.Check, 40, fields, netTags)
 
 	// Image Stats
 	fields = map[string]float64{
@@ -82,7 +119,7 @@ func TestDockerCheck(t *testing.T) {
 		"docker.images.intermediate": 0,
 	}
 	imageTags := []string{"service:docker"}
-	testutil.AssertCheckWithMetrics(t, d.Check, 17, fields, imageTags)
+	testutil.AssertCheckWithMetrics(t, d.Check, 23, fields, imageTags)
 
 	// Image Size
 	fields = map[string]float64{
@@ -90,5 +127,203 @@ func TestDockerCheck(t *testing.T) {
 		"docker.image.size":         0,
 	}
 	imageTags = []string{"service:docker", "image_name:quay.io:4443/coreos/etcd", "image_tag:v2.2.2"}
-	testutil.AssertCheckWithMetrics(t, d.Check, 17, fields, imageTags)
+	testutil.AssertCheckWithMetrics(t, d.Check, 23, fields, imageTags)
+}
+
+func TestDockerEvents(t *testing.T) {
+	d := Docker{
+		CollectEvents:       true,
+		Tags:                []string{"service:docker"},
+		CollectLabelsAsTags: []string{"com.docker.compose.service"},
+		tagNames:            make(map[string][]string),
+		excludePatterns:     make(map[string]bool),
+		includePatterns:     make(map[string]bool),
+		filteredContainers:  make(map[string]bool),
+		testing:             true,
+	}
+
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	// The first check only records the time to get the events from.
+	require.NoError(t, d.Check(agg))
+	agg.Flush()
+	for len(metricC) > 0 {
+		m := <-metricC
+		_, ok := m.Value.(metric.Event)
+		assert.False(t, ok)
+	}
+
+	require.NoError(t, d.Check(agg))
+	agg.Flush()
+	var events []metric.Event
+	for len(metricC) > 0 {
+		m := <-metricC
+		if e, ok := m.Value.(metric.Event); ok {
+			events = append(events, e)
+		}
+	}
+	require.Len(t, events, 2)
+
+	assert.Equal(t, "Docker container etcd: die, start (3 events)", events[0].Title)
+	assert.Equal(t, "2016-02-20T04:18:50Z die (exit code 137)\n"+
+		"2016-02-20T04:18:51Z start\n"+
+		"2016-02-20T04:18:55Z die (exit code 137)", events[0].Text)
+	assert.Equal(t, "error", events[0].AlertType)
+	assert.Equal(t, "docker:e2173b9478a6ae55e237d4d74f8bbb753f0817192b5081334dc78476296b7dfb", events[0].AggregationKey)
+	assert.Equal(t, "docker", events[0].SourceTypeName)
+	assert.Equal(t, []string{
+		"service:docker",
+		"com.docker.compose.service:cloudinsight",
+		"container_name:etcd",
+		"docker_image:quay.io/coreos/etcd:v2.2.2",
+		"image_name:quay.io/coreos/etcd",
+		"image_tag:v2.2.2",
+	}, events[0].Tags)
+
+	assert.Equal(t, "Docker image redis:latest: pull", events[1].Title)
+	assert.Equal(t, "info", events[1].AlertType)
+	assert.Equal(t, []string{"service:docker", "image_name:redis", "image_tag:latest"}, events[1].Tags)
+}
+
+func TestDockerContainerHealth(t *testing.T) {
+	d := Docker{
+		Tags:               []string{"service:docker"},
+		tagNames:           make(map[string][]string),
+		excludePatterns:    make(map[string]bool),
+		includePatterns:    make(map[string]bool),
+		filteredContainers: make(map[string]bool),
+		testing:            true,
+	}
+
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+	require.NoError(t, d.Check(agg))
+	agg.Flush()
+
+	var serviceChecks []metric.ServiceCheck
+	for len(metricC) > 0 {
+		m := <-metricC
+		if sc, ok := m.Value.(metric.ServiceCheck); ok {
+			serviceChecks = append(serviceChecks, sc)
+		}
+	}
+	require.Len(t, serviceChecks, 2)
+	for _, sc := range serviceChecks {
+		assert.Equal(t, "docker.container_health", sc.Check)
+		assert.Equal(t, metric.StatusCritical, sc.Status)
+		assert.Equal(t, "curl: (7) Failed to connect to localhost port 2379", sc.Message)
+	}
+}
+
+func TestDockerStoppedContainerHealth(t *testing.T) {
+	d := Docker{
+		testing: true,
+	}
+
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+	container := types.Container{ID: stoppedContainerID}
+	require.NoError(t, d.collectContainerInspect(container, agg, nil))
+	agg.Flush()
+
+	// The stale health status of the last run isn't reported.
+	var names []string
+	for len(metricC) > 0 {
+		m := <-metricC
+		_, isServiceCheck := m.Value.(metric.ServiceCheck)
+		assert.False(t, isServiceCheck)
+		names = append(names, m.Name)
+	}
+	assert.Contains(t, names, "docker.container.restarts")
+}
+
+func TestDockerDiskUsage(t *testing.T) {
+	d := Docker{
+		CollectDiskUsage:   true,
+		Tags:               []string{"service:docker"},
+		tagNames:           make(map[string][]string),
+		excludePatterns:    make(map[string]bool),
+		includePatterns:    make(map[string]bool),
+		filteredContainers: make(map[string]bool),
+		testing:            true,
+	}
+
+	fields := map[string]float64{
+		"docker.disk.images.count":           2,
+		"docker.disk.images.size":            1092588,
+		"docker.disk.images.reclaimable":     0,
+		"docker.disk.containers.count":       2,
+		"docker.disk.containers.size":        3072,
+		"docker.disk.containers.reclaimable": 1024,
+		"docker.disk.volumes.count":          2,
+		"docker.disk.volumes.size":           4608,
+		"docker.disk.volumes.reclaimable":    512,
+		// The shared record isn't counted in the size, like the docker CLI.
+		"docker.disk.build_cache.count":       3,
+		"docker.disk.build_cache.size":        6144,
+		"docker.disk.build_cache.reclaimable": 4096,
+	}
+	testutil.AssertCheckWithMetrics(t, d.collectDiskUsage, 16, fields, []string{"service:docker"})
+
+	fields = map[string]float64{
+		"docker.volume.size":      512,
+		"docker.volume.ref_count": 0,
+	}
+	tags := []string{"service:docker", "volume_name:orphan", "volume_driver:local"}
+	d.lastDiskUsageTime = time.Time{}
+	testutil.AssertCheckWithMetrics(t, d.collectDiskUsage, 16, fields, tags)
+
+	// The disk usage is collected at a slower interval than the check.
+	testutil.AssertCheckWithMetrics(t, d.collectDiskUsage, 0, nil, nil)
+}
+
+func TestDFClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_ping":
+			w.Header().Set("API-Version", "1.35")
+			fmt.Fprint(w, "OK")
+		// The version of the daemon is older than dfAPIVersion.
+		case "/v1.35/system/df":
+			fmt.Fprint(w, `{"LayersSize":1024,"Images":[],"Containers":[],"Volumes":[],"BuilderSize":4096}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := newDFClient("tcp://" + strings.TrimPrefix(ts.URL, "http://"))
+	require.NoError(t, err)
+	du, err := c.DiskUsage(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1024, du.LayersSize)
+	require.NotNil(t, du.BuilderSize)
+	assert.EqualValues(t, 4096, *du.BuilderSize)
+	assert.Nil(t, du.BuildCache)
+}
+
+func TestDFClientNewerDaemon(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_ping":
+			w.Header().Set("API-Version", "1.45")
+			fmt.Fprint(w, "OK")
+		case "/v" + dfAPIVersion + "/system/df":
+			fmt.Fprint(w, `{"LayersSize":1024,"Images":[],"Containers":[],"Volumes":[],"BuildCache":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := newDFClient("tcp://" + strings.TrimPrefix(ts.URL, "http://"))
+	require.NoError(t, err)
+	du, err := c.DiskUsage(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, dfAPIVersion, c.version)
+	assert.NotNil(t, du.BuildCache)
 }
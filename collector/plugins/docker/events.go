@@ -0,0 +1,175 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// The container and image actions turned into events.
+var (
+	containerEventActions = []string{"start", "die", "oom", "kill", "restart"}
+	imageEventActions     = []string{"pull", "delete"}
+)
+
+// collectEvents gets the events which happened since the last check, the
+// daemon keeps the recent events in memory so that the restarts of a crash
+// loop are reported even when they happen faster than the check interval.
+func (d *Docker) collectEvents(agg metric.Aggregator) error {
+	now := time.Now()
+	if d.lastEventTime.IsZero() {
+		// Don't report the events which happened before the agent started.
+		d.lastEventTime = now
+		return nil
+	}
+
+	args := filters.NewArgs()
+	args.Add("type", events.ContainerEventType)
+	args.Add("type", events.ImageEventType)
+	for _, action := range containerEventActions {
+		args.Add("event", action)
+	}
+	for _, action := range imageEventActions {
+		args.Add("event", action)
+	}
+	opts := types.EventsOptions{
+		Since:   formatEventTime(d.lastEventTime),
+		Until:   formatEventTime(now),
+		Filters: args,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.timeout)*time.Second)
+	defer cancel()
+	messages, errs := eventsWrapper(d.client, ctx, opts)
+
+	var msgs []events.Message
+	for done := false; !done; {
+		select {
+		case msg := <-messages:
+			msgs = append(msgs, msg)
+		case err := <-errs:
+			if err != nil && err != io.EOF {
+				return err
+			}
+			done = true
+		}
+	}
+	d.lastEventTime = now
+
+	for _, e := range d.aggregateEvents(msgs) {
+		agg.AddEvent(e)
+	}
+	return nil
+}
+
+// aggregateEvents groups the messages by container or image, so that a
+// crash loop is reported as a single event.
+func (d *Docker) aggregateEvents(msgs []events.Message) []metric.Event {
+	var keys []string
+	groups := make(map[string][]events.Message)
+	for _, msg := range msgs {
+		key := msg.Type + ":" + msg.Actor.ID
+		if msg.Type == events.ContainerEventType && d.isContainerExcluded(eventContainer(msg)) {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], msg)
+	}
+
+	result := make([]metric.Event, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		first := group[0]
+
+		var name string
+		var tags []string
+		if first.Type == events.ContainerEventType {
+			container := eventContainer(first)
+			name = "container " + extractContainerName(container)
+			tags = d.getTags(container, PERFORMANCE)
+		} else {
+			image := eventImage(first)
+			name = "image " + image.RepoTags[0]
+			tags = d.getTags(image, IMAGE)
+		}
+
+		var actions, lines []string
+		alertType := "info"
+		for _, msg := range group {
+			if !util.StringInSlice(msg.Action, actions) {
+				actions = append(actions, msg.Action)
+			}
+
+			line := fmt.Sprintf("%s %s", time.Unix(msg.Time, 0).UTC().Format(time.RFC3339), msg.Action)
+			switch msg.Action {
+			case "die":
+				exitCode := msg.Actor.Attributes["exitCode"]
+				line += fmt.Sprintf(" (exit code %s)", exitCode)
+				if exitCode != "0" {
+					alertType = "error"
+				}
+			case "kill":
+				line += fmt.Sprintf(" (signal %s)", msg.Actor.Attributes["signal"])
+			case "oom":
+				alertType = "error"
+			}
+			lines = append(lines, line)
+		}
+
+		title := fmt.Sprintf("Docker %s: %s", name, strings.Join(actions, ", "))
+		if len(group) > 1 {
+			title = fmt.Sprintf("%s (%d events)", title, len(group))
+		}
+
+		result = append(result, metric.Event{
+			Title:          title,
+			Text:           strings.Join(lines, "\n"),
+			AlertType:      alertType,
+			AggregationKey: "docker:" + first.Actor.ID,
+			SourceTypeName: "docker",
+			Tags:           tags,
+		})
+	}
+	return result
+}
+
+// eventContainer builds the container of an event from the attributes of
+// its actor, which contain the labels of the container, so that it can be
+// tagged even after it has been removed.
+func eventContainer(msg events.Message) types.Container {
+	attrs := msg.Actor.Attributes
+	return types.Container{
+		ID:     msg.Actor.ID,
+		Names:  []string{"/" + attrs["name"]},
+		Image:  attrs["image"],
+		Labels: attrs,
+	}
+}
+
+// eventImage builds the image of an event, the actor is the reference of the
+// image for a pull, and its ID for a delete.
+func eventImage(msg events.Message) types.ImageSummary {
+	ref := msg.Actor.ID
+	if name, ok := msg.Actor.Attributes["name"]; ok && strings.HasPrefix(ref, "sha256:") {
+		ref = name
+	}
+	return types.ImageSummary{
+		ID:       msg.Actor.ID,
+		RepoTags: []string{ref},
+		Labels:   msg.Actor.Attributes,
+	}
+}
+
+func formatEventTime(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
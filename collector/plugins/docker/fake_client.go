@@ -2,10 +2,13 @@ package docker
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"io/ioutil"
 	"strings"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 )
 
 // FakeDockerClient XXX
@@ -96,11 +99,34 @@ func (d FakeDockerClient) ContainerList(octx context.Context, options types.Cont
 // ContainerStats XXX
 func (d FakeDockerClient) ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error) {
 	var stat types.ContainerStats
-	jsonStat := `{"read":"2016-02-24T11:42:27.472459608-05:00","memory_stats":{"stats":{},"limit":18935443456},"blkio_stats":{"io_service_bytes_recursive":[{"major":252,"minor":1,"op":"Read","value":753664},{"major":252,"minor":1,"op":"Write"},{"major":252,"minor":1,"op":"Sync"},{"major":252,"minor":1,"op":"Async","value":753664},{"major":252,"minor":1,"op":"Total","value":753664}],"io_serviced_recursive":[{"major":252,"minor":1,"op":"Read","value":26},{"major":252,"minor":1,"op":"Write"},{"major":252,"minor":1,"op":"Sync"},{"major":252,"minor":1,"op":"Async","value":26},{"major":252,"minor":1,"op":"Total","value":26}]},"cpu_stats":{"cpu_usage":{"percpu_usage":[17871,4959158,1646137,1231652,11829401,244656,369972,0],"usage_in_usermode":10000000,"total_usage":20298847},"system_cpu_usage":24052607520000000,"throttling_data":{}},"precpu_stats":{"cpu_usage":{"percpu_usage":[17871,4959158,1646137,1231652,11829401,244656,369972,0],"usage_in_usermode":10000000,"total_usage":20298847},"system_cpu_usage":24052599550000000,"throttling_data":{}}}`
+	jsonStat := `{"read":"2016-02-24T11:42:27.472459608-05:00","memory_stats":{"stats":{},"limit":18935443456},"blkio_stats":{"io_service_bytes_recursive":[{"major":252,"minor":1,"op":"Read","value":753664},{"major":252,"minor":1,"op":"Write"},{"major":252,"minor":1,"op":"Sync"},{"major":252,"minor":1,"op":"Async","value":753664},{"major":252,"minor":1,"op":"Total","value":753664}],"io_serviced_recursive":[{"major":252,"minor":1,"op":"Read","value":26},{"major":252,"minor":1,"op":"Write"},{"major":252,"minor":1,"op":"Sync"},{"major":252,"minor":1,"op":"Async","value":26},{"major":252,"minor":1,"op":"Total","value":26}]},"cpu_stats":{"cpu_usage":{"percpu_usage":[17871,4959158,1646137,1231652,11829401,244656,369972,0],"usage_in_usermode":10000000,"total_usage":20298847},"system_cpu_usage":24052607520000000,"throttling_data":{"periods":120,"throttled_periods":3,"throttled_time":52000000}},"pids_stats":{"current":12,"limit":100},"networks":{"eth0":{"rx_bytes":1296,"rx_packets":16,"rx_errors":0,"rx_dropped":0,"tx_bytes":648,"tx_packets":8,"tx_errors":0,"tx_dropped":0}},"precpu_stats":{"cpu_usage":{"percpu_usage":[17871,4959158,1646137,1231652,11829401,244656,369972,0],"usage_in_usermode":10000000,"total_usage":20298847},"system_cpu_usage":24052599550000000,"throttling_data":{}}}`
 	stat.Body = ioutil.NopCloser(strings.NewReader(jsonStat))
 	return stat, nil
 }
 
+// stoppedContainerID is inspected as an exited container.
+const stoppedContainerID = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// ContainerInspect XXX
+func (d FakeDockerClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	var info types.ContainerJSON
+	state := `"Status":"running","Running":true`
+	if containerID == stoppedContainerID {
+		state = `"Status":"exited","Running":false,"ExitCode":1`
+	}
+	jsonInfo := `{"Id":"` + containerID + `","State":{` + state + `,"Health":{"Status":"unhealthy","FailingStreak":3,"Log":[{"ExitCode":1,"Output":"curl: (7) Failed to connect to localhost port 2379\n"}]}},"RestartCount":2,"HostConfig":{"CpuShares":512,"NanoCpus":1500000000,"PidsLimit":100}}`
+	err := json.Unmarshal([]byte(jsonInfo), &info)
+	return info, err
+}
+
+// DiskUsage XXX
+func (d FakeDockerClient) DiskUsage(ctx context.Context) (diskUsage, error) {
+	var du diskUsage
+	jsonDiskUsage := `{"LayersSize":1092588,"Images":[{"Id":"sha256:2b8fd9751c4c0f5dd266fcae00707e67a2545ef34f9a29354585f93dac906749","RepoTags":["busybox:latest"],"Size":1092588,"SharedSize":0,"Containers":1},{"Id":"sha256:4cdd17613acc5e2e590682db14c8df18a911e8e5d932862bb40adc800b797113","RepoTags":["quay.io:4443/coreos/etcd:v2.2.2"],"Size":0,"SharedSize":0,"VirtualSize":0,"Containers":0}],"Containers":[{"Id":"e2173b9478a6ae55e237d4d74f8bbb753f0817192b5081334dc78476296b7dfb","Names":["/etcd"],"State":"running","SizeRw":2048},{"Id":"b7dfbb9478a6ae55e237d4d74f8bbb753f0817192b5081334dc78476296e2173","Names":["/etcd2"],"State":"exited","SizeRw":1024}],"Volumes":[{"Name":"etcd-data","Driver":"local","Mountpoint":"/var/lib/docker/volumes/etcd-data/_data","Scope":"local","UsageData":{"Size":4096,"RefCount":1}},{"Name":"orphan","Driver":"local","Mountpoint":"/var/lib/docker/volumes/orphan/_data","Scope":"local","UsageData":{"Size":512,"RefCount":0}}],"BuildCache":[{"ID":"k2c5ub5dkfmqd0g6mq9zhzw4f","Type":"regular","Size":2048,"InUse":true,"Shared":false},{"ID":"s9pb4cz7s5jw4vf6pxjxw2b0m","Type":"regular","Size":4096,"InUse":false,"Shared":false},{"ID":"wmkh4ypd3bhpxnkl7t2a3lhq2","Type":"source.local","Size":1000,"InUse":false,"Shared":true}]}`
+	err := json.Unmarshal([]byte(jsonDiskUsage), &du)
+	return du, err
+}
+
 // ImageList XXX
 func (d FakeDockerClient) ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
 	image := types.ImageSummary{
@@ -114,3 +140,53 @@ func (d FakeDockerClient) ImageList(ctx context.Context, options types.ImageList
 	images := []types.ImageSummary{image}
 	return images, nil
 }
+
+// Events XXX
+func (d FakeDockerClient) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	attributes := map[string]string{
+		"com.docker.compose.service": "cloudinsight",
+		"image":                      "quay.io/coreos/etcd:v2.2.2",
+		"name":                       "etcd",
+	}
+	dieAttributes := map[string]string{"exitCode": "137"}
+	for k, v := range attributes {
+		dieAttributes[k] = v
+	}
+	containerID := "e2173b9478a6ae55e237d4d74f8bbb753f0817192b5081334dc78476296b7dfb"
+	msgs := []events.Message{
+		{
+			Type:   events.ContainerEventType,
+			Action: "die",
+			Actor:  events.Actor{ID: containerID, Attributes: dieAttributes},
+			Time:   1455941930,
+		},
+		{
+			Type:   events.ContainerEventType,
+			Action: "start",
+			Actor:  events.Actor{ID: containerID, Attributes: attributes},
+			Time:   1455941931,
+		},
+		{
+			Type:   events.ContainerEventType,
+			Action: "die",
+			Actor:  events.Actor{ID: containerID, Attributes: dieAttributes},
+			Time:   1455941935,
+		},
+		{
+			Type:   events.ImageEventType,
+			Action: "pull",
+			Actor:  events.Actor{ID: "redis:latest", Attributes: map[string]string{"name": "redis"}},
+			Time:   1455941940,
+		},
+	}
+
+	messages := make(chan events.Message)
+	errs := make(chan error, 1)
+	go func() {
+		for _, msg := range msgs {
+			messages <- msg
+		}
+		errs <- io.EOF
+	}()
+	return messages, errs
+}
@@ -1,16 +1,21 @@
 package haproxy
 
 import (
+	"bytes"
 	"encoding/csv"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
 	"github.com/cloudinsight/cloudinsight-agent/common/metric"
 	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
 )
@@ -25,6 +30,20 @@ type HAProxy struct {
 	URL      string
 	Username string
 	Password string
+
+	CollectAggregatesOnly bool     `yaml:"collect_aggregates_only"`
+	ServicesInclude       []string `yaml:"services_include"`
+	ServicesExclude       []string `yaml:"services_exclude"`
+
+	// The metrics of the backend servers used to be named after the servers,
+	// haproxy.<server>.*, they are still sent until this is disabled.
+	DisableLegacyServerMetrics bool `yaml:"disable_legacy_server_metrics"`
+
+	Tags []string
+
+	servicesInclude []*regexp.Regexp
+	servicesExclude []*regexp.Regexp
+	filtersCompiled bool
 }
 
 var (
@@ -60,8 +79,34 @@ var (
 		"hrsp_5xx":   "response.5xx",   // HA Proxy 1.4 and higher
 		"hrsp_other": "response.other", // HA Proxy 1.4 and higher
 	}
+
+	// INFO_GAUGES XXX
+	INFO_GAUGES = map[string]string{
+		"Uptime_sec":   "uptime",
+		"CurrConns":    "conns.current",
+		"Maxconn":      "conns.limit",
+		"ConnRate":     "conns.per_sec",
+		"SessRate":     "sessions.per_sec",
+		"CurrSslConns": "ssl_conns.current",
+		"Run_queue":    "run_queue",
+		"Tasks":        "tasks",
+		"Idle_pct":     "idle_pct",
+	}
+
+	// INFO_RATES XXX
+	INFO_RATES = map[string]string{
+		"CumConns":    "conns.rate",
+		"CumReq":      "requests.rate",
+		"CumSslConns": "ssl_conns.rate",
+	}
+
+	// The statuses of the servers which are always reported, so that the
+	// counts drop to 0 instead of disappearing.
+	serverStatuses = []string{"up", "down", "maint", "drain"}
 )
 
+const socketTimeout = 4 * time.Second
+
 var tr = &http.Transport{
 	ResponseHeaderTimeout: time.Duration(3 * time.Second),
 }
@@ -73,6 +118,10 @@ var client = &http.Client{
 
 // Check XXX
 func (h *HAProxy) Check(agg metric.Aggregator) error {
+	if strings.HasPrefix(h.URL, "unix://") {
+		return h.checkSocket(agg, strings.TrimPrefix(h.URL, "unix://"))
+	}
+
 	requestURI := h.URL
 	if !strings.HasSuffix(h.URL, ";csv;norefresh") {
 		requestURI += ";csv;norefresh"
@@ -103,6 +152,71 @@ func (h *HAProxy) Check(agg metric.Aggregator) error {
 	return h.collectHAStats(agg, resp.Body, u.Host)
 }
 
+// checkSocket collects the stats over the stats socket, which also provides
+// the process-wide metrics of show info.
+func (h *HAProxy) checkSocket(agg metric.Aggregator, path string) error {
+	stats, err := socketCommand(path, "show stat")
+	if err != nil {
+		return err
+	}
+	err = h.collectHAStats(agg, bytes.NewReader(stats), path)
+	if err != nil {
+		return err
+	}
+
+	info, err := socketCommand(path, "show info")
+	if err != nil {
+		return err
+	}
+	h.collectInfo(agg, info, path)
+	return nil
+}
+
+// socketCommand runs a command on the stats socket, which closes the
+// connection after the response unless it is in interactive mode.
+func socketCommand(path, command string) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", path, socketTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Unable connect to stats socket '%s': %s", path, err)
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(socketTimeout))
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write([]byte(command + "\n"))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(conn)
+}
+
+func (h *HAProxy) collectInfo(agg metric.Aggregator, info []byte, host string) {
+	tags := make([]string, 0, len(h.Tags)+1)
+	tags = append(tags, h.Tags...)
+	tags = append(tags, "server:"+host)
+
+	for _, line := range strings.Split(string(info), "\n") {
+		record := strings.SplitN(line, ":", 2)
+		if len(record) < 2 {
+			continue
+		}
+		key := strings.TrimSpace(record[0])
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			continue
+		}
+
+		if name, ok := INFO_GAUGES[key]; ok {
+			agg.Add("gauge", metric.NewMetric("haproxy.process."+name, value, tags))
+		}
+		if name, ok := INFO_RATES[key]; ok {
+			agg.Add("rate", metric.NewMetric("haproxy.process."+name, value, tags))
+		}
+	}
+}
+
 func (h *HAProxy) collectHAStats(agg metric.Aggregator, statsBody io.Reader, host string) error {
 	reader := csv.NewReader(statsBody)
 	result, err := reader.ReadAll()
@@ -110,6 +224,7 @@ func (h *HAProxy) collectHAStats(agg metric.Aggregator, statsBody io.Reader, hos
 		return err
 	}
 	var fields []string
+	statusCounts := make(map[string]map[string]int)
 
 	for i, row := range result {
 		if i == 0 {
@@ -117,43 +232,175 @@ func (h *HAProxy) collectHAStats(agg metric.Aggregator, statsBody io.Reader, hos
 			fields = row
 			continue
 		}
-		tags := []string{
-			"server:" + host,
-			"proxy:" + row[0],
-			"type:" + row[1],
+		service, svname := row[0], row[1]
+		if h.isServiceExcluded(service) {
+			continue
 		}
-		var scur, slim float64
-		for k, v := range row {
-			value, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				continue
-			}
-			field := fields[k]
-			if field == "scur" {
-				scur = value
-			}
-			if field == "slim" {
-				slim = value
-			}
 
-			if name, ok := GAUGES[field]; ok {
-				name = fmt.Sprintf("haproxy.%s.%s", strings.ToLower(row[1]), name)
-				agg.Add("gauge", metric.NewMetric(name, value, tags))
-			}
+		tags := make([]string, 0, len(h.Tags)+5)
+		tags = append(tags, h.Tags...)
+		tags = append(tags,
+			"server:"+host,
+			"proxy:"+service,
+		)
 
-			if name, ok := RATES[field]; ok {
-				name = fmt.Sprintf("haproxy.%s.%s", strings.ToLower(row[1]), name)
-				agg.Add("rate", metric.NewMetric(name, value, tags))
+		isAggregate := svname == "FRONTEND" || svname == "BACKEND"
+		if isAggregate {
+			tags = append(tags, "type:"+svname, "service:"+service)
+			addRowMetrics(agg, "haproxy."+strings.ToLower(svname), fields, row, tags)
+			continue
+		}
+
+		serverTags := make([]string, 0, len(tags)+3)
+		serverTags = append(serverTags, tags...)
+		serverTags = append(serverTags, "type:SERVER", "service:"+service, "backend_server:"+svname)
+		status := normalizeStatus(rowValue(fields, row, "status"))
+		if statusCounts[service] == nil {
+			statusCounts[service] = make(map[string]int)
+		}
+		statusCounts[service][status]++
+		h.checkServerStatus(agg, status, fields, row, serverTags)
+
+		if h.CollectAggregatesOnly {
+			continue
+		}
+		addRowMetrics(agg, "haproxy.server", fields, row, serverTags)
+		if !h.DisableLegacyServerMetrics {
+			legacyTags := make([]string, 0, len(tags)+1)
+			legacyTags = append(legacyTags, tags...)
+			legacyTags = append(legacyTags, "type:"+svname)
+			addRowMetrics(agg, "haproxy."+strings.ToLower(svname), fields, row, legacyTags)
+		}
+	}
+
+	for service, counts := range statusCounts {
+		for _, status := range serverStatuses {
+			if _, ok := counts[status]; !ok {
+				counts[status] = 0
 			}
 		}
-		if slim != 0 {
-			name := fmt.Sprintf("haproxy.%s.%s", strings.ToLower(row[1]), GAUGES["spct"])
-			agg.Add("gauge", metric.NewMetric(name, scur/slim*100, tags))
+		for status, count := range counts {
+			tags := make([]string, 0, len(h.Tags)+3)
+			tags = append(tags, h.Tags...)
+			tags = append(tags, "server:"+host, "service:"+service, "status:"+status)
+			agg.Add("gauge", metric.NewMetric("haproxy.server.count_per_status", count, tags))
 		}
 	}
 	return nil
 }
 
+func addRowMetrics(agg metric.Aggregator, prefix string, fields, row, tags []string) {
+	var scur, slim float64
+	for k, v := range row {
+		value, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		field := fields[k]
+		if field == "scur" {
+			scur = value
+		}
+		if field == "slim" {
+			slim = value
+		}
+
+		if name, ok := GAUGES[field]; ok {
+			agg.Add("gauge", metric.NewMetric(prefix+"."+name, value, tags))
+		}
+
+		if name, ok := RATES[field]; ok {
+			agg.Add("rate", metric.NewMetric(prefix+"."+name, value, tags))
+		}
+	}
+	if slim != 0 {
+		agg.Add("gauge", metric.NewMetric(prefix+"."+GAUGES["spct"], scur/slim*100, tags))
+	}
+}
+
+// checkServerStatus reports the status of a backend server as a service
+// check, a server in maintenance or draining is a warning.
+func (h *HAProxy) checkServerStatus(agg metric.Aggregator, status string, fields, row, tags []string) {
+	var scStatus int
+	switch status {
+	case "up":
+		scStatus = metric.StatusOK
+	case "down":
+		scStatus = metric.StatusCritical
+	case "maint", "drain":
+		scStatus = metric.StatusWarning
+	default:
+		scStatus = metric.StatusUnknown
+	}
+
+	var msg string
+	if scStatus != metric.StatusOK {
+		msg = fmt.Sprintf("Server status is %s", rowValue(fields, row, "status"))
+		if check := rowValue(fields, row, "check_status"); check != "" {
+			msg += fmt.Sprintf(", last check status is %s", check)
+		}
+	}
+	agg.AddServiceCheck(metric.NewServiceCheck("haproxy.backend_up", scStatus, msg, tags))
+}
+
+// isServiceExcluded tells if a service is filtered out, the exclude rules
+// apply first and the include rules override them.
+func (h *HAProxy) isServiceExcluded(service string) bool {
+	if !h.filtersCompiled {
+		h.servicesExclude = compilePatterns(h.ServicesExclude)
+		h.servicesInclude = compilePatterns(h.ServicesInclude)
+		h.filtersCompiled = true
+	}
+	if !matchPatterns(service, h.servicesExclude) {
+		return false
+	}
+	return !matchPatterns(service, h.servicesInclude)
+}
+
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Warnf("Invalid service pattern %s. %s", pattern, err)
+			continue
+		}
+		res = append(res, re)
+	}
+	return res
+}
+
+func matchPatterns(s string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func rowValue(fields, row []string, field string) string {
+	for k, name := range fields {
+		if name == field && k < len(row) {
+			return row[k]
+		}
+	}
+	return ""
+}
+
+// normalizeStatus turns the status of a server into up, down, maint, drain,
+// no_check, etc. The status looks like "UP", "UP 1/3", "MAINT (via b/s)"
+// or "no check".
+func normalizeStatus(status string) string {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status == "no check" {
+		return "no_check"
+	}
+	if fields := strings.Fields(status); len(fields) > 0 {
+		return fields[0]
+	}
+	return "unknown"
+}
+
 func init() {
 	collector.Add("haproxy", NewHAProxy)
 }
@@ -1,12 +1,21 @@
 package haproxy
 
 import (
+	"bufio"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var hastats = `
@@ -55,6 +64,7 @@ func TestHAProxyCheck(t *testing.T) {
 		"server:" + server,
 		"proxy:hastats",
 		"type:FRONTEND",
+		"service:hastats",
 	}
 	testutil.AssertCheckWithMetrics(t, h.Check, 12, fields, tags)
 
@@ -72,6 +82,7 @@ func TestHAProxyCheck(t *testing.T) {
 		"server:" + server,
 		"proxy:hastats",
 		"type:BACKEND",
+		"service:hastats",
 	}
 	testutil.AssertCheckWithMetrics(t, h.Check, 12, fields, tags)
 
@@ -93,6 +104,7 @@ func TestHAProxyCheck(t *testing.T) {
 		"server:" + server,
 		"proxy:hastats",
 		"type:FRONTEND",
+		"service:hastats",
 	}
 	testutil.AssertCheckWithRateMetrics(t, h.Check, h2.Check, 39, fields, tags)
 
@@ -117,6 +129,155 @@ func TestHAProxyCheck(t *testing.T) {
 		"server:" + server,
 		"proxy:hastats",
 		"type:BACKEND",
+		"service:hastats",
 	}
 	testutil.AssertCheckWithRateMetrics(t, h.Check, h2.Check, 39, fields, tags)
 }
+
+var socketStats = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,rate,rate_lim,rate_max,check_status,check_code,check_duration,hrsp_1xx,hrsp_2xx,hrsp_3xx,hrsp_4xx,hrsp_5xx,hrsp_other,hanafail,req_rate,req_rate_max,req_tot,cli_abrt,srv_abrt,comp_in,comp_out,comp_byp,comp_rsp,lastsess,last_chk,last_agt,qtime,ctime,rtime,ttime,
+web,FRONTEND,,,3,5,2000,120,40960,81920,0,0,0,,,,,OPEN,,,,,,,,,1,2,0,,,,0,2,0,5,,,,0,118,0,2,0,0,,2,5,120,,,0,0,0,0,,,,,,,,
+app,web1,0,0,2,3,,60,20480,40960,,0,,0,0,0,0,UP,1,1,0,0,0,3600,0,,1,3,1,,60,,2,1,,3,L7OK,200,1,0,59,0,1,0,0,0,,,,0,0,,,,,1,OK,,0,1,2,10,
+app,web2,0,0,1,2,,60,20480,40960,,0,,0,0,0,0,DOWN,1,1,0,3,1,60,60,,1,3,2,,60,,2,1,,3,L4CON,,0,0,59,0,1,0,0,0,,,,0,0,,,,,1,Connection refused,,0,1,2,10,
+app,web3,0,0,0,0,,0,0,0,,0,,0,0,0,0,MAINT,1,1,0,0,0,60,60,,1,3,3,,0,,2,0,,0,,,,0,0,0,0,0,0,0,,,,0,0,,,,,-1,,,0,0,0,0,
+app,BACKEND,0,0,3,5,200,120,40960,81920,0,0,,0,0,0,0,UP,2,2,0,,0,3600,0,,1,3,0,,120,,1,2,,5,,,,0,118,0,2,0,0,,,,120,0,0,0,0,0,0,1,,,0,1,2,10,
+admin,FRONTEND,,,0,1,2000,3,0,0,0,0,0,,,,,OPEN,,,,,,,,,1,4,0,,,,0,0,0,1,,,,0,3,0,0,0,0,,0,1,3,,,0,0,0,0,,,,,,,,
+`
+
+var socketInfo = `Name: HAProxy
+Version: 1.8.8
+Nbproc: 1
+Uptime_sec: 3600
+Maxconn: 2000
+CurrConns: 3
+CumConns: 150
+CumReq: 180
+ConnRate: 2
+SessRate: 2
+Run_queue: 0
+Tasks: 12
+Idle_pct: 98
+`
+
+func serveStatsSocket(t *testing.T, path string) net.Listener {
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			command, _ := bufio.NewReader(conn).ReadString('\n')
+			switch strings.TrimSpace(command) {
+			case "show stat":
+				fmt.Fprintln(conn, socketStats)
+			case "show info":
+				fmt.Fprintln(conn, socketInfo)
+			}
+			conn.Close()
+		}
+	}()
+	return l
+}
+
+func TestHAProxySocketCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "haproxy.sock")
+	l := serveStatsSocket(t, path)
+	defer l.Close()
+
+	h := HAProxy{
+		URL:             "unix://" + path,
+		ServicesExclude: []string{"^admin$"},
+		Tags:            []string{"env:test"},
+	}
+
+	fields := map[string]float64{
+		"haproxy.process.uptime":           3600,
+		"haproxy.process.conns.current":    3,
+		"haproxy.process.conns.limit":      2000,
+		"haproxy.process.conns.per_sec":    2,
+		"haproxy.process.sessions.per_sec": 2,
+		"haproxy.process.run_queue":        0,
+		"haproxy.process.tasks":            12,
+		"haproxy.process.idle_pct":         98,
+	}
+	tags := []string{"env:test", "server:" + path}
+	testutil.AssertCheckWithMetrics(t, h.Check, 63, fields, tags)
+
+	fields = map[string]float64{
+		"haproxy.server.session.current": 2,
+		"haproxy.server.queue.time":      0,
+		"haproxy.server.response.time":   2,
+	}
+	tags = []string{"env:test", "server:" + path, "proxy:app", "type:SERVER", "service:app", "backend_server:web1"}
+	testutil.AssertCheckWithMetrics(t, h.Check, 63, fields, tags)
+
+	fields = map[string]float64{
+		"haproxy.server.count_per_status": 1,
+	}
+	for _, status := range []string{"up", "down", "maint"} {
+		tags = []string{"env:test", "server:" + path, "service:app", "status:" + status}
+		testutil.AssertCheckWithMetrics(t, h.Check, 63, fields, tags)
+	}
+	fields = map[string]float64{
+		"haproxy.server.count_per_status": 0,
+	}
+	tags = []string{"env:test", "server:" + path, "service:app", "status:drain"}
+	testutil.AssertCheckWithMetrics(t, h.Check, 63, fields, tags)
+
+	// The legacy server metrics are named after the servers.
+	fields = map[string]float64{
+		"haproxy.web1.session.current": 2,
+		"haproxy.web1.response.time":   2,
+	}
+	tags = []string{"env:test", "server:" + path, "proxy:app", "type:web1"}
+	testutil.AssertCheckWithMetrics(t, h.Check, 63, fields, tags)
+
+	h.DisableLegacyServerMetrics = true
+	testutil.AssertCheckWithMetrics(t, h.Check, 45, nil, nil)
+
+	// The servers are only reported by their status with aggregates only.
+	h.CollectAggregatesOnly = true
+	testutil.AssertCheckWithMetrics(t, h.Check, 27, nil, nil)
+}
+
+func TestHAProxyServerStatus(t *testing.T) {
+	h := HAProxy{
+		ServicesExclude: []string{".*"},
+		ServicesInclude: []string{"^app$"},
+	}
+
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+	require.NoError(t, h.collectHAStats(agg, strings.NewReader(socketStats), "localhost"))
+	agg.Flush()
+
+	statuses := make(map[string]int)
+	messages := make(map[string]string)
+	for len(metricC) > 0 {
+		m := <-metricC
+		if sc, ok := m.Value.(metric.ServiceCheck); ok {
+			require.Equal(t, "haproxy.backend_up", sc.Check)
+			server := sc.Tags[len(sc.Tags)-1]
+			statuses[server] = sc.Status
+			messages[server] = sc.Message
+		}
+	}
+	assert.Equal(t, map[string]int{
+		"backend_server:web1": metric.StatusOK,
+		"backend_server:web2": metric.StatusCritical,
+		"backend_server:web3": metric.StatusWarning,
+	}, statuses)
+	assert.Equal(t, "Server status is DOWN, last check status is L4CON", messages["backend_server:web2"])
+}
+
+func TestNormalizeStatus(t *testing.T) {
+	assert.Equal(t, "up", normalizeStatus("UP 1/3"))
+	assert.Equal(t, "down", normalizeStatus("DOWN"))
+	assert.Equal(t, "maint", normalizeStatus("MAINT (via app/web1)"))
+	assert.Equal(t, "no_check", normalizeStatus("no check"))
+}
@@ -0,0 +1,398 @@
+package kubelet
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+)
+
+// NewKubelet XXX
+func NewKubelet(conf plugin.InitConfig) plugin.Plugin {
+	return &Kubelet{}
+}
+
+// Kubelet XXX
+type Kubelet struct {
+	URL                string
+	BearerTokenPath    string   `yaml:"bearer_token_path"`
+	CAPath             string   `yaml:"ca_path"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify"`
+	PodLabelsAsTags    []string `yaml:"pod_labels_as_tags"`
+	Timeout            int
+	Tags               []string
+
+	client *http.Client
+}
+
+// Summary stores the information from /stats/summary
+type Summary struct {
+	Node NodeStats  `json:"node"`
+	Pods []PodStats `json:"pods"`
+}
+
+// NodeStats XXX
+type NodeStats struct {
+	NodeName string        `json:"nodeName"`
+	CPU      *CPUStats     `json:"cpu"`
+	Memory   *MemoryStats  `json:"memory"`
+	Network  *NetworkStats `json:"network"`
+	Fs       *FsStats      `json:"fs"`
+	Runtime  *struct {
+		ImageFs *FsStats `json:"imageFs"`
+	} `json:"runtime"`
+}
+
+// PodStats XXX
+type PodStats struct {
+	PodRef struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		UID       string `json:"uid"`
+	} `json:"podRef"`
+	Containers       []ContainerStats `json:"containers"`
+	CPU              *CPUStats        `json:"cpu"`
+	Memory           *MemoryStats     `json:"memory"`
+	Network          *NetworkStats    `json:"network"`
+	EphemeralStorage *FsStats         `json:"ephemeral-storage"`
+}
+
+// ContainerStats XXX
+type ContainerStats struct {
+	Name   string       `json:"name"`
+	CPU    *CPUStats    `json:"cpu"`
+	Memory *MemoryStats `json:"memory"`
+	Rootfs *FsStats     `json:"rootfs"`
+	Logs   *FsStats     `json:"logs"`
+}
+
+// CPUStats XXX
+type CPUStats struct {
+	UsageNanoCores       float64 `json:"usageNanoCores"`
+	UsageCoreNanoSeconds float64 `json:"usageCoreNanoSeconds"`
+}
+
+// MemoryStats XXX
+type MemoryStats struct {
+	AvailableBytes  *float64 `json:"availableBytes"`
+	UsageBytes      float64  `json:"usageBytes"`
+	WorkingSetBytes float64  `json:"workingSetBytes"`
+	RSSBytes        float64  `json:"rssBytes"`
+	PageFaults      float64  `json:"pageFaults"`
+	MajorPageFaults float64  `json:"majorPageFaults"`
+}
+
+// NetworkStats XXX
+type NetworkStats struct {
+	RxBytes  float64 `json:"rxBytes"`
+	RxErrors float64 `json:"rxErrors"`
+	TxBytes  float64 `json:"txBytes"`
+	TxErrors float64 `json:"txErrors"`
+}
+
+// FsStats XXX
+type FsStats struct {
+	AvailableBytes *float64 `json:"availableBytes"`
+	CapacityBytes  *float64 `json:"capacityBytes"`
+	UsedBytes      float64  `json:"usedBytes"`
+	InodesFree     *float64 `json:"inodesFree"`
+	InodesUsed     *float64 `json:"inodesUsed"`
+}
+
+// PodList stores the information from /pods
+type PodList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string            `json:"name"`
+			Namespace string            `json:"namespace"`
+			UID       string            `json:"uid"`
+			Labels    map[string]string `json:"labels"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+const (
+	defaultURL             = "https://localhost:10250"
+	defaultBearerTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultCAPath          = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	defaultTimeout         = 5
+)
+
+// Check XXX
+func (k *Kubelet) Check(agg metric.Aggregator) error {
+	if k.client == nil {
+		c, err := k.newClient()
+		if err != nil {
+			return err
+		}
+		k.client = c
+	}
+
+	summary := Summary{}
+	err := k.get("/stats/summary", &summary)
+	if err != nil {
+		return err
+	}
+
+	// The labels of pods are only available from /pods
+	podLabels := make(map[string][]string)
+	if len(k.PodLabelsAsTags) > 0 {
+		podLabels, err = k.getPodLabels()
+		if err != nil {
+			log.Warnf("Failed to get the labels of pods, %s", err)
+		}
+	}
+
+	k.collectNodeStats(summary.Node, agg)
+	for _, pod := range summary.Pods {
+		k.collectPodStats(pod, podLabels[pod.PodRef.UID], agg)
+	}
+	return nil
+}
+
+func (k *Kubelet) collectNodeStats(node NodeStats, agg metric.Aggregator) {
+	tags := k.newTags("kube_node:" + node.NodeName)
+
+	submitCPUStats("kubernetes.node", node.CPU, tags, agg)
+	submitMemoryStats("kubernetes.node", node.Memory, tags, agg)
+	submitNetworkStats("kubernetes.node", node.Network, tags, agg)
+	submitFsStats("kubernetes.node.filesystem", node.Fs, tags, agg)
+	if node.Runtime != nil {
+		submitFsStats("kubernetes.node.image_filesystem", node.Runtime.ImageFs, tags, agg)
+	}
+}
+
+func (k *Kubelet) collectPodStats(pod PodStats, labelTags []string, agg metric.Aggregator) {
+	tags := k.newTags(
+		"kube_namespace:"+pod.PodRef.Namespace,
+		"pod_name:"+pod.PodRef.Name,
+	)
+	tags = append(tags, labelTags...)
+
+	submitCPUStats("kubernetes.pod", pod.CPU, tags, agg)
+	submitMemoryStats("kubernetes.pod", pod.Memory, tags, agg)
+	submitNetworkStats("kubernetes.pod", pod.Network, tags, agg)
+	submitFsStats("kubernetes.pod.ephemeral_storage", pod.EphemeralStorage, tags, agg)
+
+	for _, c := range pod.Containers {
+		containerTags := append(append([]string{}, tags...), "kube_container_name:"+c.Name)
+
+		submitCPUStats("kubernetes.container", c.CPU, containerTags, agg)
+		submitMemoryStats("kubernetes.container", c.Memory, containerTags, agg)
+		submitFsStats("kubernetes.container.filesystem", c.Rootfs, containerTags, agg)
+		submitFsStats("kubernetes.container.logs", c.Logs, containerTags, agg)
+	}
+}
+
+func (k *Kubelet) getPodLabels() (map[string][]string, error) {
+	pods := PodList{}
+	err := k.get("/pods", &pods)
+	if err != nil {
+		return nil, err
+	}
+
+	podLabels := make(map[string][]string)
+	for _, pod := range pods.Items {
+		var tags []string
+		for _, name := range k.PodLabelsAsTags {
+			if val, ok := pod.Metadata.Labels[name]; ok {
+				tags = append(tags, name+":"+val)
+			}
+		}
+		podLabels[pod.Metadata.UID] = tags
+	}
+	return podLabels, nil
+}
+
+func submitCPUStats(prefix string, stats *CPUStats, tags []string, agg metric.Aggregator) {
+	if stats == nil {
+		return
+	}
+
+	agg.Add("gauge", metric.NewMetric(prefix+".cpu.usage", stats.UsageNanoCores, tags))
+	agg.Add("rate", metric.NewMetric(prefix+".cpu.usage_total", stats.UsageCoreNanoSeconds, tags))
+}
+
+func submitMemoryStats(prefix string, stats *MemoryStats, tags []string, agg metric.Aggregator) {
+	if stats == nil {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"usage":       stats.UsageBytes,
+		"working_set": stats.WorkingSetBytes,
+		"rss":         stats.RSSBytes,
+	}
+	if stats.AvailableBytes != nil {
+		fields["available"] = *stats.AvailableBytes
+	}
+	agg.AddMetrics("gauge", prefix+".memory", fields, tags, "")
+
+	fields = map[string]interface{}{
+		"page_faults":       stats.PageFaults,
+		"major_page_faults": stats.MajorPageFaults,
+	}
+	agg.AddMetrics("rate", prefix+".memory", fields, tags, "")
+}
+
+func submitNetworkStats(prefix string, stats *NetworkStats, tags []string, agg metric.Aggregator) {
+	if stats == nil {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"rx_bytes":  stats.RxBytes,
+		"rx_errors": stats.RxErrors,
+		"tx_bytes":  stats.TxBytes,
+		"tx_errors": stats.TxErrors,
+	}
+	agg.AddMetrics("rate", prefix+".network", fields, tags, "")
+}
+
+func submitFsStats(prefix string, stats *FsStats, tags []string, agg metric.Aggregator) {
+	if stats == nil {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"usage": stats.UsedBytes,
+	}
+	if stats.CapacityBytes != nil {
+		fields["capacity"] = *stats.CapacityBytes
+	}
+	if stats.AvailableBytes != nil {
+		fields["available"] = *stats.AvailableBytes
+	}
+	if stats.InodesFree != nil {
+		fields["inodes_free"] = *stats.InodesFree
+	}
+	if stats.InodesUsed != nil {
+		fields["inodes_used"] = *stats.InodesUsed
+	}
+	agg.AddMetrics("gauge", prefix, fields, tags, "")
+}
+
+func (k *Kubelet) newClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: k.InsecureSkipVerify,
+	}
+
+	// The CA is only needed to verify the secure port, the read-only port
+	// is plain HTTP.
+	if !k.InsecureSkipVerify && strings.HasPrefix(k.url(), "https://") {
+		pool, err := k.loadCA()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	timeout := k.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:       tlsConfig,
+			ResponseHeaderTimeout: time.Duration(timeout) * time.Second,
+		},
+		Timeout: time.Duration(timeout+1) * time.Second,
+	}, nil
+}
+
+// loadCA loads the configured CA certificate, or the CA of the service
+// account. It returns nil to use the system roots when ca_path is unset and
+// the agent isn't running in a pod.
+func (k *Kubelet) loadCA() (*x509.CertPool, error) {
+	caPath := k.CAPath
+	if caPath == "" {
+		caPath = defaultCAPath
+	}
+	ca, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		if k.CAPath == "" && os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Unable to read the CA certificate %s: %s", caPath, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("No valid certificate found in %s", caPath)
+	}
+	return pool, nil
+}
+
+func (k *Kubelet) url() string {
+	if k.URL == "" {
+		return defaultURL
+	}
+	return k.URL
+}
+
+func (k *Kubelet) get(path string, v interface{}) error {
+	requestURI := strings.TrimSuffix(k.url(), "/") + path
+
+	req, err := http.NewRequest("GET", requestURI, nil)
+	if err != nil {
+		return err
+	}
+
+	token, err := k.getBearerToken()
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making HTTP request to %s: %s", requestURI, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP status %s", requestURI, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// getBearerToken reads the token on every request, since the service account
+// token may be rotated.
+func (k *Kubelet) getBearerToken() (string, error) {
+	path := k.BearerTokenPath
+	if path == "" {
+		path = defaultBearerTokenPath
+	}
+
+	token, err := ioutil.ReadFile(path)
+	if err != nil {
+		if k.BearerTokenPath == "" {
+			// Running outside of a pod, e.g. with the read-only port.
+			return "", nil
+		}
+		return "", fmt.Errorf("Unable to read the bearer token %s: %s", path, err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+func (k *Kubelet) newTags(extra ...string) []string {
+	tags := make([]string, 0, len(k.Tags)+len(extra))
+	tags = append(tags, k.Tags...)
+	return append(tags, extra...)
+}
+
+func init() {
+	collector.Add("kubelet", NewKubelet)
+}
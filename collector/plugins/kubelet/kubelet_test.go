@@ -0,0 +1,237 @@
+package kubelet
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+var (
+	statsSummary = `{
+  "node": {
+    "nodeName": "node-1",
+    "cpu": {"usageNanoCores": 182535394, "usageCoreNanoSeconds": 1048576000000},
+    "memory": {
+      "availableBytes": 2147483648,
+      "usageBytes": 3221225472,
+      "workingSetBytes": 1610612736,
+      "rssBytes": 1073741824,
+      "pageFaults": 1000,
+      "majorPageFaults": 10
+    },
+    "network": {"rxBytes": 1024, "rxErrors": 0, "txBytes": 2048, "txErrors": 0},
+    "fs": {
+      "availableBytes": 50000000000,
+      "capacityBytes": 100000000000,
+      "usedBytes": 50000000000,
+      "inodesFree": 6000000,
+      "inodesUsed": 500000
+    },
+    "runtime": {
+      "imageFs": {
+        "availableBytes": 50000000000,
+        "capacityBytes": 100000000000,
+        "usedBytes": 2000000000,
+        "inodesFree": 6000000,
+        "inodesUsed": 500000
+      }
+    }
+  },
+  "pods": [
+    {
+      "podRef": {"name": "nginx-1", "namespace": "default", "uid": "uid-1"},
+      "cpu": {"usageNanoCores": 1000000, "usageCoreNanoSeconds": 5000000000},
+      "memory": {"usageBytes": 4194304, "workingSetBytes": 2097152, "rssBytes": 1048576},
+      "network": {"rxBytes": 512, "rxErrors": 0, "txBytes": 256, "txErrors": 0},
+      "ephemeral-storage": {"usedBytes": 40960, "inodesUsed": 12},
+      "containers": [
+        {
+          "name": "nginx",
+          "cpu": {"usageNanoCores": 1000000, "usageCoreNanoSeconds": 5000000000},
+          "memory": {"usageBytes": 4194304, "workingSetBytes": 2097152, "rssBytes": 1048576},
+          "rootfs": {"usedBytes": 32768, "inodesUsed": 10},
+          "logs": {"usedBytes": 8192}
+        }
+      ]
+    }
+  ]
+}`
+
+	pods = `{
+  "kind": "PodList",
+  "items": [
+    {
+      "metadata": {
+        "name": "nginx-1",
+        "namespace": "default",
+        "uid": "uid-1",
+        "labels": {"app": "nginx", "pod-template-hash": "12345"}
+      }
+    }
+  ]
+}`
+)
+
+func newHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var rsp string
+		switch r.URL.Path {
+		case "/stats/summary":
+			rsp = statsSummary
+		case "/pods":
+			rsp = pods
+		default:
+			panic("Cannot handle request")
+		}
+		fmt.Fprintln(w, rsp)
+	})
+}
+
+func writeTempFile(t *testing.T, content []byte) string {
+	f, err := ioutil.TempFile("", "kubelet")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(content)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func TestKubeletCheck(t *testing.T) {
+	ts := httptest.NewServer(newHandler(""))
+	defer ts.Close()
+
+	k := &Kubelet{
+		URL:                ts.URL,
+		InsecureSkipVerify: true,
+		PodLabelsAsTags:    []string{"app"},
+		Tags:               []string{"service:kubelet"},
+	}
+
+	fields := map[string]float64{
+		"kubernetes.node.cpu.usage":                  182535394,
+		"kubernetes.node.memory.usage":               3221225472,
+		"kubernetes.node.memory.available":           2147483648,
+		"kubernetes.node.filesystem.capacity":        100000000000,
+		"kubernetes.node.filesystem.inodes_free":     6000000,
+		"kubernetes.node.image_filesystem.usage":     2000000000,
+		"kubernetes.node.image_filesystem.available": 50000000000,
+	}
+	tags := []string{"service:kubelet", "kube_node:node-1"}
+	testutil.AssertCheckWithMetrics(t, k.Check, 28, fields, tags)
+
+	fields = map[string]float64{
+		"kubernetes.pod.cpu.usage":                     1000000,
+		"kubernetes.pod.memory.working_set":            2097152,
+		"kubernetes.pod.ephemeral_storage.usage":       40960,
+		"kubernetes.pod.ephemeral_storage.inodes_used": 12,
+	}
+	tags = []string{"service:kubelet", "kube_namespace:default", "pod_name:nginx-1", "app:nginx"}
+	testutil.AssertCheckWithMetrics(t, k.Check, 28, fields, tags)
+
+	fields = map[string]float64{
+		"kubernetes.container.memory.rss":             1048576,
+		"kubernetes.container.filesystem.usage":       32768,
+		"kubernetes.container.filesystem.inodes_used": 10,
+		"kubernetes.container.logs.usage":             8192,
+	}
+	tags = []string{"service:kubelet", "kube_namespace:default", "pod_name:nginx-1", "app:nginx", "kube_container_name:nginx"}
+	testutil.AssertCheckWithMetrics(t, k.Check, 28, fields, tags)
+}
+
+func TestKubeletCheckWithRates(t *testing.T) {
+	ts := httptest.NewServer(newHandler(""))
+	defer ts.Close()
+
+	k := &Kubelet{
+		URL:                ts.URL,
+		InsecureSkipVerify: true,
+	}
+
+	fields := map[string]float64{
+		"kubernetes.node.network.rx_bytes":   0,
+		"kubernetes.node.memory.page_faults": 0,
+	}
+	tags := []string{"kube_node:node-1"}
+	// 28 gauges, 7 node rates, 7 pod rates and 3 container rates
+	testutil.AssertCheckWithRateMetrics(t, k.Check, k.Check, 45, fields, tags)
+}
+
+func TestKubeletCheckWithAuth(t *testing.T) {
+	ts := httptest.NewTLSServer(newHandler("secret-token"))
+	defer ts.Close()
+
+	tokenPath := writeTempFile(t, []byte("secret-token\n"))
+	defer os.Remove(tokenPath)
+	caPath := writeTempFile(t, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ts.Certificate().Raw,
+	}))
+	defer os.Remove(caPath)
+
+	k := &Kubelet{
+		URL:             ts.URL,
+		BearerTokenPath: tokenPath,
+		CAPath:          caPath,
+	}
+	testutil.AssertCheckWithLen(t, k.Check, 28)
+}
+
+func TestKubeletCheckUnauthorized(t *testing.T) {
+	ts := httptest.NewServer(newHandler("secret-token"))
+	defer ts.Close()
+
+	tokenPath := writeTempFile(t, []byte("wrong-token"))
+	defer os.Remove(tokenPath)
+
+	k := &Kubelet{
+		URL:                ts.URL,
+		BearerTokenPath:    tokenPath,
+		InsecureSkipVerify: true,
+	}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	err := k.Check(testutil.MockAggregator(metricC))
+	assert.Error(t, err)
+}
+
+func TestKubeletMissingCA(t *testing.T) {
+	k := &Kubelet{
+		CAPath: "/nonexistent/ca.crt",
+	}
+	_, err := k.newClient()
+	assert.Error(t, err)
+}
+
+func TestKubeletReadOnlyPortWithoutCA(t *testing.T) {
+	k := &Kubelet{
+		URL:    "http://localhost:10255",
+		CAPath: "/nonexistent/ca.crt",
+	}
+	_, err := k.newClient()
+	assert.NoError(t, err)
+}
+
+func TestKubeletDefaultCAMissing(t *testing.T) {
+	if _, err := os.Stat(defaultCAPath); err == nil {
+		t.Skip("running in a pod")
+	}
+	k := &Kubelet{}
+	c, err := k.newClient()
+	require.NoError(t, err)
+	assert.Nil(t, c.Transport.(*http.Transport).TLSClientConfig.RootCAs)
+}
@@ -0,0 +1,226 @@
+package linuxkernel
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+)
+
+// NewLinuxKernel XXX
+func NewLinuxKernel(conf plugin.InitConfig) plugin.Plugin {
+	return &LinuxKernel{}
+}
+
+// LinuxKernel collects the metrics of the kernel from procfs.
+type LinuxKernel struct {
+	ProcRoot string `yaml:"proc_root"`
+	Tags     []string
+}
+
+const defaultProcRoot = "/proc"
+
+var (
+	// STAT_GAUGES XXX
+	STAT_GAUGES = map[string]string{
+		"procs_running": "linux.procs.running",
+		"procs_blocked": "linux.procs.blocked",
+	}
+
+	// STAT_RATES XXX
+	STAT_RATES = map[string]string{
+		"ctxt":      "linux.context_switches",
+		"intr":      "linux.interrupts",
+		"processes": "linux.processes_created",
+	}
+
+	// VMSTAT_RATES XXX
+	VMSTAT_RATES = map[string]string{
+		"pgfault":    "linux.mem.page_faults",
+		"pgmajfault": "linux.mem.major_page_faults",
+		"pgpgin":     "linux.mem.page_in",
+		"pgpgout":    "linux.mem.page_out",
+		"pswpin":     "linux.mem.swap_in",
+		"pswpout":    "linux.mem.swap_out",
+	}
+
+	// VMSTAT_MONOTONICCOUNTS XXX
+	VMSTAT_MONOTONICCOUNTS = map[string]string{
+		// Only available since Linux 4.13
+		"oom_kill": "linux.mem.oom_kills",
+	}
+
+	// The resources of the pressure stall information, since Linux 4.20.
+	psiResources = []string{"cpu", "memory", "io"}
+)
+
+// Check XXX
+func (k *LinuxKernel) Check(agg metric.Aggregator) error {
+	root := k.ProcRoot
+	if root == "" {
+		root = defaultProcRoot
+	}
+
+	if err := k.collectStat(root, agg); err != nil {
+		return err
+	}
+	if err := k.collectVMStat(root, agg); err != nil {
+		return err
+	}
+	k.collectPressure(root, agg)
+	k.collectFileHandles(root, agg)
+	k.collectEntropy(root, agg)
+	return nil
+}
+
+func (k *LinuxKernel) collectStat(root string, agg metric.Aggregator) error {
+	kv, err := readKeyValues(filepath.Join(root, "stat"))
+	if err != nil {
+		return err
+	}
+	k.submit(agg, "gauge", STAT_GAUGES, kv)
+	k.submit(agg, "rate", STAT_RATES, kv)
+	return nil
+}
+
+func (k *LinuxKernel) collectVMStat(root string, agg metric.Aggregator) error {
+	kv, err := readKeyValues(filepath.Join(root, "vmstat"))
+	if err != nil {
+		return err
+	}
+	k.submit(agg, "rate", VMSTAT_RATES, kv)
+	k.submit(agg, "monotoniccount", VMSTAT_MONOTONICCOUNTS, kv)
+	return nil
+}
+
+// collectPressure collects the pressure stall information, e.g.
+// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+// full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+// The averages are the percentages of time stalled, and the total is the
+// time stalled in microseconds.
+func (k *LinuxKernel) collectPressure(root string, agg metric.Aggregator) {
+	for _, resource := range psiResources {
+		f, err := os.Open(filepath.Join(root, "pressure", resource))
+		if err != nil {
+			continue
+		}
+
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			fields := strings.Fields(sc.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			prefix := fmt.Sprintf("linux.pressure.%s.%s", resource, fields[0])
+			for _, field := range fields[1:] {
+				parts := strings.SplitN(field, "=", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				v, err := strconv.ParseFloat(parts[1], 64)
+				if err != nil {
+					continue
+				}
+				metricType := "gauge"
+				if parts[0] == "total" {
+					metricType = "rate"
+				}
+				agg.Add(metricType, metric.NewMetric(prefix+"."+parts[0], v, k.Tags))
+			}
+		}
+		f.Close()
+	}
+}
+
+// collectFileHandles collects the allocated, unused and max file handles of
+// sys/fs/file-nr, e.g. "1024	0	100000".
+func (k *LinuxKernel) collectFileHandles(root string, agg metric.Aggregator) {
+	data, err := ioutil.ReadFile(filepath.Join(root, "sys", "fs", "file-nr"))
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 3 {
+		return
+	}
+
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		if values[i], err = strconv.ParseFloat(field, 64); err != nil {
+			return
+		}
+	}
+	allocated, unused, max := values[0], values[1], values[2]
+
+	metrics := map[string]interface{}{
+		"allocated": allocated,
+		"unused":    unused,
+		"max":       max,
+		"in_use":    allocated - unused,
+	}
+	if max > 0 {
+		metrics["in_use_pct"] = (allocated - unused) / max * 100
+	}
+	agg.AddMetrics("gauge", "linux.fs.file_handles", metrics, k.Tags, "")
+}
+
+func (k *LinuxKernel) collectEntropy(root string, agg metric.Aggregator) {
+	data, err := ioutil.ReadFile(filepath.Join(root, "sys", "kernel", "random", "entropy_avail"))
+	if err != nil {
+		return
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return
+	}
+	agg.Add("gauge", metric.NewMetric("linux.entropy.available", v, k.Tags))
+}
+
+func (k *LinuxKernel) submit(agg metric.Aggregator, metricType string, names map[string]string, kv map[string]float64) {
+	for key, name := range names {
+		if v, ok := kv[key]; ok {
+			agg.Add(metricType, metric.NewMetric(name, v, k.Tags))
+		}
+	}
+}
+
+// readKeyValues reads the first value of each key of a file, e.g. vmstat
+// pgfault 21905041
+// pgmajfault 621
+// The first value of intr of stat is the total of all the interrupts.
+func readKeyValues(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	kv := make(map[string]float64)
+	sc := bufio.NewScanner(f)
+	// The intr line of /proc/stat is longer than the default buffer on
+	// hosts with many interrupts.
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		kv[fields[0]] = v
+	}
+	return kv, sc.Err()
+}
+
+func init() {
+	collector.Add("linux_kernel", NewLinuxKernel)
+}
@@ -0,0 +1,84 @@
+package linuxkernel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+func TestLinuxKernel(t *testing.T) {
+	k := &LinuxKernel{
+		ProcRoot: "testdata/proc",
+		Tags:     []string{"env:test"},
+	}
+
+	fields := map[string]float64{
+		"linux.procs.running":               3,
+		"linux.procs.blocked":               1,
+		"linux.pressure.cpu.some.avg10":     1.25,
+		"linux.pressure.cpu.some.avg60":     1.58,
+		"linux.pressure.cpu.some.avg300":    1.86,
+		"linux.pressure.memory.some.avg10":  0.5,
+		"linux.pressure.memory.full.avg10":  0.2,
+		"linux.pressure.memory.full.avg300": 0.05,
+		"linux.pressure.io.some.avg300":     0.02,
+		"linux.pressure.io.full.avg10":      0,
+		"linux.fs.file_handles.allocated":   2048,
+		"linux.fs.file_handles.unused":      48,
+		"linux.fs.file_handles.max":         100000,
+		"linux.fs.file_handles.in_use":      2000,
+		"linux.fs.file_handles.in_use_pct":  2,
+		"linux.entropy.available":           3754,
+	}
+	// 15 pressure averages, 5 file handles, 2 procs and the entropy
+	testutil.AssertCheckWithMetrics(t, k.Check, 23, fields, []string{"env:test"}, 0.0001)
+}
+
+func TestLinuxKernelWithRates(t *testing.T) {
+	k := &LinuxKernel{
+		ProcRoot: "testdata/proc",
+	}
+
+	fields := map[string]float64{
+		"linux.context_switches":       0,
+		"linux.interrupts":             0,
+		"linux.processes_created":      0,
+		"linux.mem.page_faults":        0,
+		"linux.mem.swap_out":           0,
+		"linux.pressure.io.full.total": 0,
+		"linux.mem.oom_kills":          0,
+	}
+	// 23 gauges, 14 rates and 1 monotonic count
+	testutil.AssertCheckWithRateMetrics(t, k.Check, k.Check, 38, fields, nil)
+}
+
+func TestLinuxKernelOptionalFiles(t *testing.T) {
+	k := &LinuxKernel{
+		ProcRoot: "testdata/minimal",
+	}
+
+	// Only the procs of stat without pressure, file-nr and entropy_avail
+	testutil.AssertCheckWithLen(t, k.Check, 2)
+}
+
+func TestLinuxKernelMissingProc(t *testing.T) {
+	k := &LinuxKernel{
+		ProcRoot: "testdata/missing",
+	}
+
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	err := k.Check(testutil.MockAggregator(metricC))
+	assert.Error(t, err)
+}
+
+func TestReadKeyValues(t *testing.T) {
+	kv, err := readKeyValues("testdata/proc/stat")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(932146), kv["intr"])
+	assert.Equal(t, float64(2010654), kv["ctxt"])
+	assert.Equal(t, float64(41658), kv["processes"])
+}
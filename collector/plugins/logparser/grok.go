@@ -0,0 +1,90 @@
+package logparser
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// The built-in grok patterns, which are a subset of the patterns of Logstash
+// rewritten for RE2, e.g. without look-around assertions.
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"POSINT":            `[1-9][0-9]*`,
+	"NONNEGINT":         `[0-9]+`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:[0-9]{1,3}\.){3}[0-9]{1,3}`,
+	"IPV6":              `[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`,
+	"IP":                `(?:%{IPV4}|%{IPV6})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"PATH":              `(?:/[^\s?#]*)+`,
+	"URIPATHPARAM":      `%{PATH}(?:\?\S*)?`,
+	"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert)`,
+	"MONTH":             `\b(?:Jan(?:uary)?|Feb(?:ruary)?|Mar(?:ch)?|Apr(?:il)?|May|Jun(?:e)?|Jul(?:y)?|Aug(?:ust)?|Sep(?:tember)?|Oct(?:ober)?|Nov(?:ember)?|Dec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:0[1-9]|[12][0-9]|3[01]|[1-9])`,
+	"YEAR":              `[0-9]{4}`,
+	"TIME":              `[0-9]{2}:[0-9]{2}:[0-9]{2}(?:[.,][0-9]+)?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{TIME}(?:Z|[+-][0-9]{2}:?[0-9]{2})?`,
+	"COMMONAPACHELOG":   `%{IPORHOST:clientip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{NUMBER:response} (?:%{NUMBER:bytes}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QUOTEDSTRING:referrer} %{QUOTEDSTRING:agent}`,
+}
+
+// e.g. %{NUMBER} or %{NUMBER:duration}
+var grokRef = regexp.MustCompile(`%\{(\w+)(?::(\w+))?\}`)
+
+const maxGrokDepth = 16
+
+// compileGrok compiles a grok pattern into a regexp, the named references
+// become named capturing groups. A pattern without any reference is a plain
+// regexp.
+func compileGrok(pattern string, custom map[string]string) (*regexp.Regexp, error) {
+	expanded, err := expandGrok(pattern, custom, 0)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(expanded)
+}
+
+func expandGrok(pattern string, custom map[string]string, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", fmt.Errorf("grok patterns are nested too deeply: %s", pattern)
+	}
+
+	var err error
+	expanded := grokRef.ReplaceAllStringFunc(pattern, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+
+		m := grokRef.FindStringSubmatch(ref)
+		def, ok := custom[m[1]]
+		if !ok {
+			def, ok = grokPatterns[m[1]]
+		}
+		if !ok {
+			err = fmt.Errorf("Unknown grok pattern: %s", m[1])
+			return ""
+		}
+
+		var sub string
+		sub, err = expandGrok(def, custom, depth+1)
+		if m[2] != "" {
+			return "(?P<" + m[2] + ">" + sub + ")"
+		}
+		return "(?:" + sub + ")"
+	})
+	return expanded, err
+}
@@ -0,0 +1,65 @@
+package logparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileGrok(t *testing.T) {
+	re, err := compileGrok(`%{IPORHOST:client} took %{NUMBER:duration}ms`, nil)
+	require.NoError(t, err)
+
+	m := re.FindStringSubmatch("10.0.0.1 took 12.5ms")
+	require.NotNil(t, m)
+	assert.Equal(t, "10.0.0.1", m[re.SubexpIndex("client")])
+	assert.Equal(t, "12.5", m[re.SubexpIndex("duration")])
+}
+
+func TestCompileGrokCombinedApacheLog(t *testing.T) {
+	re, err := compileGrok(`%{COMBINEDAPACHELOG}`, nil)
+	require.NoError(t, err)
+
+	line := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`
+	m := re.FindStringSubmatch(line)
+	require.NotNil(t, m)
+	assert.Equal(t, "127.0.0.1", m[re.SubexpIndex("clientip")])
+	assert.Equal(t, "frank", m[re.SubexpIndex("auth")])
+	assert.Equal(t, "GET", m[re.SubexpIndex("verb")])
+	assert.Equal(t, "/apache_pb.gif?a=1", m[re.SubexpIndex("request")])
+	assert.Equal(t, "200", m[re.SubexpIndex("response")])
+	assert.Equal(t, "2326", m[re.SubexpIndex("bytes")])
+}
+
+func TestCompileGrokCustomPatterns(t *testing.T) {
+	custom := map[string]string{
+		"DURATION": `%{NUMBER}(?:ms|s)`,
+		"REQUEST":  `%{WORD:method} %{DURATION:duration}`,
+	}
+	re, err := compileGrok(`%{LOGLEVEL:level} %{REQUEST}`, custom)
+	require.NoError(t, err)
+
+	m := re.FindStringSubmatch("ERROR POST 3s")
+	require.NotNil(t, m)
+	assert.Equal(t, "ERROR", m[re.SubexpIndex("level")])
+	assert.Equal(t, "POST", m[re.SubexpIndex("method")])
+	assert.Equal(t, "3s", m[re.SubexpIndex("duration")])
+}
+
+func TestCompileGrokPlainRegexp(t *testing.T) {
+	re, err := compileGrok(`status=(?P<status>\d+)`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "status=(?P<status>\\d+)", re.String())
+}
+
+func TestCompileGrokErrors(t *testing.T) {
+	_, err := compileGrok(`%{UNKNOWN:foo}`, nil)
+	assert.EqualError(t, err, "Unknown grok pattern: UNKNOWN")
+
+	_, err = compileGrok(`%{LOOP}`, map[string]string{"LOOP": `a%{LOOP}`})
+	assert.Error(t, err)
+
+	_, err = compileGrok(`(%{WORD}`, nil)
+	assert.Error(t, err)
+}
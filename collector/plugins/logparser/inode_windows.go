@@ -0,0 +1,9 @@
+package logparser
+
+import "os"
+
+// Files have no inode on Windows, the offsets are kept as long as the files
+// aren't truncated.
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
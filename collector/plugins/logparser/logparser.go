@@ -0,0 +1,286 @@
+package logparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+	"github.com/cloudinsight/cloudinsight-agent/common/util"
+)
+
+// NewLogParser XXX
+func NewLogParser(conf plugin.InitConfig) plugin.Plugin {
+	return &LogParser{}
+}
+
+// LogParser tails log files and turns the matched lines into metrics.
+type LogParser struct {
+	Files         []string
+	OffsetFile    string `yaml:"offset_file"`
+	FromBeginning bool   `yaml:"from_beginning"`
+	Patterns      map[string]string
+	Metrics       []MetricConfig
+	Tags          []string
+
+	parsers     []*parser
+	tailers     map[string]*tailer
+	positions   map[string]position
+	initialized bool
+}
+
+// MetricConfig describes a metric generated from the lines matching the
+// pattern.
+type MetricConfig struct {
+	Name    string
+	Type    string
+	Pattern string
+	Value   string
+	Tags    []string
+}
+
+type parser struct {
+	MetricConfig
+	metricType string
+	re         *regexp.Regexp
+}
+
+const defaultOffsetDir = "/var/lib/cloudinsight-agent"
+
+// The types of metrics supported, mapped to the ones of the aggregator.
+var metricTypes = map[string]string{
+	"counter":   "count",
+	"gauge":     "gauge",
+	"histogram": "histogram",
+}
+
+// Check XXX
+func (l *LogParser) Check(agg metric.Aggregator) error {
+	if l.parsers == nil {
+		parsers, err := l.compile()
+		if err != nil {
+			return err
+		}
+		l.parsers = parsers
+	}
+
+	if l.tailers == nil {
+		l.tailers = make(map[string]*tailer)
+		l.positions = l.loadPositions()
+	}
+
+	seen := make(map[string]bool)
+	for _, pattern := range l.Files {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			t, err := l.getTailer(path)
+			if err != nil {
+				log.Errorf("Failed to open log file %s: %s", path, err)
+				continue
+			}
+			err = t.readLines(func(line string) {
+				l.parseLine(line, agg)
+			})
+			if err != nil {
+				log.Errorf("Failed to read log file %s: %s", path, err)
+			}
+		}
+	}
+
+	// Forget the files which have been removed.
+	for path, t := range l.tailers {
+		if !seen[path] {
+			t.close()
+			delete(l.tailers, path)
+		}
+	}
+	l.initialized = true
+
+	return l.savePositions()
+}
+
+func (l *LogParser) compile() ([]*parser, error) {
+	if len(l.Metrics) == 0 {
+		return nil, fmt.Errorf("No metrics defined")
+	}
+
+	parsers := make([]*parser, 0, len(l.Metrics))
+	for _, conf := range l.Metrics {
+		if conf.Name == "" {
+			return nil, fmt.Errorf("The name of the metric is required")
+		}
+
+		if conf.Type == "" {
+			conf.Type = "counter"
+		}
+		metricType, ok := metricTypes[conf.Type]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported type %s of metric %s", conf.Type, conf.Name)
+		}
+
+		re, err := compileGrok(conf.Pattern, l.Patterns)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern of metric %s: %s", conf.Name, err)
+		}
+
+		groups := re.SubexpNames()
+		for _, name := range append([]string{conf.Value}, conf.Tags...) {
+			if name != "" && !util.StringInSlice(name, groups) {
+				return nil, fmt.Errorf("Pattern of metric %s has no capture group %s", conf.Name, name)
+			}
+		}
+
+		parsers = append(parsers, &parser{
+			MetricConfig: conf,
+			metricType:   metricType,
+			re:           re,
+		})
+	}
+	return parsers, nil
+}
+
+func (l *LogParser) parseLine(line string, agg metric.Aggregator) {
+	for _, p := range l.parsers {
+		match := p.re.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		captures := make(map[string]string)
+		for i, name := range p.re.SubexpNames() {
+			// The first non-empty group wins, when a name is used more than once.
+			if name != "" && captures[name] == "" {
+				captures[name] = match[i]
+			}
+		}
+
+		value := 1.0
+		if p.Value != "" {
+			v, err := strconv.ParseFloat(captures[p.Value], 64)
+			if err != nil {
+				// e.g. "-" for the missing upstream response time of nginx
+				continue
+			}
+			value = v
+		}
+
+		tags := make([]string, 0, len(l.Tags)+len(p.Tags))
+		tags = append(tags, l.Tags...)
+		for _, name := range p.Tags {
+			if captures[name] != "" {
+				tags = append(tags, name+":"+captures[name])
+			}
+		}
+
+		agg.Add(p.metricType, metric.NewMetric(p.Name, value, tags))
+	}
+}
+
+// getTailer returns the tailer of the file, the files found at startup are
+// read from the persisted offset, or from the end to skip the old lines.
+func (l *LogParser) getTailer(path string) (*tailer, error) {
+	if t, ok := l.tailers[path]; ok {
+		return t, nil
+	}
+
+	var offset int64
+	if !l.initialized {
+		offset = -1
+		if l.FromBeginning {
+			offset = 0
+		}
+
+		if pos, ok := l.positions[path]; ok {
+			offset = pos.Offset
+			if info, err := os.Stat(path); err == nil && inode(info) != pos.Inode {
+				// The file has been rotated while the agent was down.
+				offset = 0
+			}
+		}
+	}
+
+	t, err := openTailer(path, offset)
+	if err != nil {
+		return nil, err
+	}
+	l.tailers[path] = t
+	return t, nil
+}
+
+func (l *LogParser) offsetFile() string {
+	if l.OffsetFile != "" {
+		return l.OffsetFile
+	}
+	// Each instance keeps its own offsets, keyed by its files, so that the
+	// instances don't overwrite the offsets of each other.
+	files := append([]string(nil), l.Files...)
+	sort.Strings(files)
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(files, "\n")))
+	return filepath.Join(defaultOffsetDir, fmt.Sprintf("logparser-%x.offsets", h.Sum64()))
+}
+
+func (l *LogParser) loadPositions() map[string]position {
+	positions := make(map[string]position)
+	data, err := ioutil.ReadFile(l.offsetFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to read the offset file: %s", err)
+		}
+		return positions
+	}
+
+	err = json.Unmarshal(data, &positions)
+	if err != nil {
+		log.Warnf("Failed to parse the offset file %s: %s", l.offsetFile(), err)
+	}
+	return positions
+}
+
+// savePositions writes the offsets to a temporary file first, so that the
+// offset file is never left half written.
+func (l *LogParser) savePositions() error {
+	positions := make(map[string]position)
+	for path, t := range l.tailers {
+		positions[path] = t.position()
+	}
+	data, err := json.Marshal(positions)
+	if err != nil {
+		return err
+	}
+
+	path := l.offsetFile()
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func init() {
+	collector.Add("logparser", NewLogParser)
+}
@@ -0,0 +1,232 @@
+package logparser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+const accessLog = `10.0.0.1 GET /api/users 200 0.012
+10.0.0.2 GET /api/users 500 0.250
+10.0.0.1 POST /api/orders 200 -
+not an access log line
+10.0.0.3 GET /api/users 200 0.038
+`
+
+func newLogParser(dir string) *LogParser {
+	return &LogParser{
+		Files:         []string{filepath.Join(dir, "*.log")},
+		OffsetFile:    filepath.Join(dir, "offsets", "logparser.offsets"),
+		FromBeginning: true,
+		Patterns: map[string]string{
+			"ACCESS": `%{IP:client} %{WORD:method} %{URIPATHPARAM:path} %{INT:status} (?:%{NUMBER:upstream_time}|-)`,
+		},
+		Metrics: []MetricConfig{
+			{
+				Name:    "nginx.requests",
+				Pattern: "%{ACCESS}",
+				Tags:    []string{"method", "status"},
+			},
+			{
+				Name:    "nginx.upstream.response_time",
+				Type:    "gauge",
+				Pattern: "%{ACCESS}",
+				Value:   "upstream_time",
+				Tags:    []string{"method"},
+			},
+		},
+		Tags: []string{"service:nginx"},
+	}
+}
+
+// check runs the check and returns the metrics by name and tags.
+func check(t *testing.T, l *LogParser) map[string]float64 {
+	metricC := make(chan metric.Metric, 1000)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	require.NoError(t, l.Check(agg))
+	agg.Flush()
+
+	metrics := make(map[string]float64)
+	for len(metricC) > 0 {
+		m := <-metricC
+		tags := append([]string{}, m.Tags...)
+		sort.Strings(tags)
+		metrics[fmt.Sprintf("%s%v", m.Name, tags)] = m.Value.(float64)
+	}
+	return metrics
+}
+
+func appendFile(t *testing.T, path, content string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+}
+
+func TestLogParserCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logparser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	appendFile(t, filepath.Join(dir, "access.log"), accessLog)
+
+	l := newLogParser(dir)
+	metrics := check(t, l)
+	assert.Equal(t, map[string]float64{
+		"nginx.requests[method:GET service:nginx status:200]":    2,
+		"nginx.requests[method:GET service:nginx status:500]":    1,
+		"nginx.requests[method:POST service:nginx status:200]":   1,
+		"nginx.upstream.response_time[method:GET service:nginx]": 0.038,
+	}, metrics)
+
+	// Nothing new
+	assert.Empty(t, check(t, l))
+
+	// The incomplete line is left for the next check.
+	appendFile(t, filepath.Join(dir, "access.log"), "10.0.0.1 GET /api/users 404 0.001\n10.0.0.1 GET")
+	metrics = check(t, l)
+	assert.Equal(t, 1.0, metrics["nginx.requests[method:GET service:nginx status:404]"])
+
+	appendFile(t, filepath.Join(dir, "access.log"), " /api/users 404 0.002\n")
+	metrics = check(t, l)
+	assert.Equal(t, 1.0, metrics["nginx.requests[method:GET service:nginx status:404]"])
+	assert.Equal(t, 0.002, metrics["nginx.upstream.response_time[method:GET service:nginx]"])
+}
+
+func TestLogParserHistogram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logparser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	appendFile(t, filepath.Join(dir, "access.log"), accessLog)
+
+	l := newLogParser(dir)
+	l.Metrics = []MetricConfig{
+		{
+			Name:    "nginx.upstream.response_time",
+			Type:    "histogram",
+			Pattern: "%{ACCESS}",
+			Value:   "upstream_time",
+		},
+	}
+	metrics := check(t, l)
+	assert.Equal(t, 3.0, metrics["nginx.upstream.response_time.count[service:nginx]"])
+	assert.Equal(t, 0.25, metrics["nginx.upstream.response_time.max[service:nginx]"])
+}
+
+func TestLogParserFromEnd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logparser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	appendFile(t, filepath.Join(dir, "access.log"), accessLog)
+
+	l := newLogParser(dir)
+	l.FromBeginning = false
+	assert.Empty(t, check(t, l))
+
+	// The files created later are always read from the beginning.
+	appendFile(t, filepath.Join(dir, "other.log"), "10.0.0.1 GET / 200 0.1\n")
+	metrics := check(t, l)
+	assert.Equal(t, 1.0, metrics["nginx.requests[method:GET service:nginx status:200]"])
+}
+
+func TestLogParserRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logparser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+	appendFile(t, path, accessLog)
+
+	l := newLogParser(dir)
+	l.Files = []string{path}
+	check(t, l)
+
+	// Lines written to the old file after the last check are not lost.
+	appendFile(t, path, "10.0.0.1 GET / 502 0.1\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path, "10.0.0.1 GET / 503 0.1\n")
+
+	metrics := check(t, l)
+	assert.Equal(t, 1.0, metrics["nginx.requests[method:GET service:nginx status:502]"])
+	assert.Equal(t, 1.0, metrics["nginx.requests[method:GET service:nginx status:503]"])
+}
+
+func TestLogParserTruncation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logparser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+	appendFile(t, path, accessLog)
+
+	l := newLogParser(dir)
+	check(t, l)
+
+	require.NoError(t, os.Truncate(path, 0))
+	appendFile(t, path, "10.0.0.1 GET / 504 0.1\n")
+	metrics := check(t, l)
+	assert.Equal(t, 1.0, metrics["nginx.requests[method:GET service:nginx status:504]"])
+}
+
+func TestLogParserOffsetFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logparser")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+	appendFile(t, path, accessLog)
+
+	check(t, newLogParser(dir))
+	appendFile(t, path, "10.0.0.1 GET / 201 0.1\n")
+
+	// A restarted agent only reads the new lines.
+	metrics := check(t, newLogParser(dir))
+	assert.Equal(t, map[string]float64{
+		"nginx.requests[method:GET service:nginx status:201]":    1,
+		"nginx.upstream.response_time[method:GET service:nginx]": 0.1,
+	}, metrics)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "offsets", "logparser.offsets"))
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(data), path))
+}
+
+func TestLogParserInvalidConfig(t *testing.T) {
+	tests := []struct {
+		metrics []MetricConfig
+		err     string
+	}{
+		{nil, "No metrics defined"},
+		{[]MetricConfig{{Pattern: "foo"}}, "The name of the metric is required"},
+		{[]MetricConfig{{Name: "foo", Type: "set"}}, "Unsupported type set of metric foo"},
+		{[]MetricConfig{{Name: "foo", Pattern: "%{FOO}"}}, "Invalid pattern of metric foo: Unknown grok pattern: FOO"},
+		{[]MetricConfig{{Name: "foo", Pattern: "%{WORD:bar}", Tags: []string{"baz"}}}, "Pattern of metric foo has no capture group baz"},
+	}
+
+	for _, test := range tests {
+		l := &LogParser{Metrics: test.metrics}
+		metricC := make(chan metric.Metric, 10)
+		err := l.Check(testutil.MockAggregator(metricC))
+		assert.EqualError(t, err, test.err)
+		close(metricC)
+	}
+}
+
+func TestLogParserDefaultOffsetFile(t *testing.T) {
+	l1 := &LogParser{Files: []string{"/var/log/a.log", "/var/log/b.log"}}
+	l2 := &LogParser{Files: []string{"/var/log/b.log", "/var/log/a.log"}}
+	l3 := &LogParser{Files: []string{"/var/log/c.log"}}
+
+	assert.Equal(t, l1.offsetFile(), l2.offsetFile())
+	assert.NotEqual(t, l1.offsetFile(), l3.offsetFile())
+	assert.Equal(t, defaultOffsetDir, filepath.Dir(l3.offsetFile()))
+}
@@ -0,0 +1,121 @@
+package logparser
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// tailer reads the complete lines appended to a file since the last read.
+type tailer struct {
+	path   string
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+}
+
+// position is the persisted state of a tailer, the inode identifies the file
+// across restarts, since the path is reused by the rotation.
+type position struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+func openTailer(path string, offset int64) (*tailer, error) {
+	t := &tailer{path: path}
+	err := t.open()
+	if err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || offset > t.info.Size() {
+		offset = t.info.Size()
+	}
+	t.offset = offset
+	return t, nil
+}
+
+func (t *tailer) open() error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	t.file = f
+	t.info = info
+	t.offset = 0
+	return nil
+}
+
+// readLines calls fn with every new line of the file. When the file has been
+// rotated, the rest of the old file is read before switching to the new one.
+func (t *tailer) readLines(fn func(line string)) error {
+	err := t.readToEnd(fn)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		// The file is being rotated, it will be reopened next time.
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if !os.SameFile(t.info, info) {
+		t.close()
+		err = t.open()
+		if err != nil {
+			return err
+		}
+	} else if info.Size() < t.offset {
+		// The file has been truncated, e.g. by copytruncate of logrotate.
+		t.offset = 0
+	} else {
+		return nil
+	}
+	return t.readToEnd(fn)
+}
+
+func (t *tailer) readToEnd(fn func(line string)) error {
+	_, err := t.file.Seek(t.offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(t.file)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			// The incomplete last line is read again next time.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t.offset += int64(len(line))
+		fn(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+func (t *tailer) position() position {
+	return position{
+		Inode:  inode(t.info),
+		Offset: t.offset,
+	}
+}
+
+func (t *tailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
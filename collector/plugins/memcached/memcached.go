@@ -23,6 +23,8 @@ type Memcached struct {
 	URL    string
 	Port   int
 	Socket string
+	Slabs  bool
+	Items  bool
 	Tags   []string
 }
 
@@ -59,6 +61,47 @@ var (
 		"cas_badval":        "memcache.cas_badval_rate",
 		"total_connections": "memcache.total_connections_rate",
 	}
+
+	// SLABS_GAUGES XXX
+	SLABS_GAUGES = map[string]string{
+		"active_slabs":    "memcache.slabs.active_slabs",
+		"total_malloced":  "memcache.slabs.total_malloced",
+		"chunk_size":      "memcache.slabs.chunk_size",
+		"chunks_per_page": "memcache.slabs.chunks_per_page",
+		"total_pages":     "memcache.slabs.total_pages",
+		"total_chunks":    "memcache.slabs.total_chunks",
+		"used_chunks":     "memcache.slabs.used_chunks",
+		"free_chunks":     "memcache.slabs.free_chunks",
+		"free_chunks_end": "memcache.slabs.free_chunks_end",
+		"mem_requested":   "memcache.slabs.mem_requested",
+	}
+
+	// SLABS_RATES XXX
+	SLABS_RATES = map[string]string{
+		"get_hits":    "memcache.slabs.get_hits_rate",
+		"cmd_set":     "memcache.slabs.cmd_set_rate",
+		"delete_hits": "memcache.slabs.delete_hits_rate",
+		"cas_hits":    "memcache.slabs.cas_hits_rate",
+		"cas_badval":  "memcache.slabs.cas_badval_rate",
+	}
+
+	// ITEMS_GAUGES XXX
+	ITEMS_GAUGES = map[string]string{
+		"number":       "memcache.items.number",
+		"age":          "memcache.items.age",
+		"evicted_time": "memcache.items.evicted_time",
+	}
+
+	// ITEMS_RATES XXX
+	ITEMS_RATES = map[string]string{
+		"evicted":           "memcache.items.evictions_rate",
+		"evicted_nonzero":   "memcache.items.evicted_nonzero_rate",
+		"evicted_unfetched": "memcache.items.evicted_unfetched_rate",
+		"expired_unfetched": "memcache.items.expired_unfetched_rate",
+		"outofmemory":       "memcache.items.outofmemory_rate",
+		"reclaimed":         "memcache.items.reclaimed_rate",
+		"tailrepairs":       "memcache.items.tailrepairs_rate",
+	}
 )
 
 // Check XXX
@@ -73,12 +116,30 @@ func (m *Memcached) Check(agg metric.Aggregator) error {
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
+
 	fmt.Fprintln(conn, "stats")
 	tags := append(m.Tags, fmt.Sprintf("url:%s:%d", m.URL, m.Port))
 	err = m.collectMetrics(conn, tags, agg)
 	if err != nil {
 		return err
 	}
+
+	if m.Slabs {
+		fmt.Fprintln(conn, "stats slabs")
+		err = m.collectSlabsMetrics(conn, "", SLABS_GAUGES, SLABS_RATES, tags, agg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.Items {
+		fmt.Fprintln(conn, "stats items")
+		err = m.collectSlabsMetrics(conn, "items:", ITEMS_GAUGES, ITEMS_RATES, tags, agg)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -136,6 +197,41 @@ func (m *Memcached) collectMetrics(conn io.Reader, tags []string, agg metric.Agg
 	return nil
 }
 
+// collectSlabsMetrics collects the output of stats slabs or stats items,
+// whose keys look like 1:chunk_size or items:1:number for the slab class 1.
+func (m *Memcached) collectSlabsMetrics(
+	conn io.Reader,
+	prefix string,
+	gauges map[string]string,
+	rates map[string]string,
+	tags []string,
+	agg metric.Aggregator,
+) error {
+	stats, err := m.parseStats(conn)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range stats {
+		key = strings.TrimPrefix(key, prefix)
+		metricTags := tags
+		if res := strings.SplitN(key, ":", 2); len(res) == 2 {
+			key = res[1]
+			metricTags = make([]string, 0, len(tags)+1)
+			metricTags = append(metricTags, tags...)
+			metricTags = append(metricTags, "slab:"+res[0])
+		}
+
+		if name, ok := gauges[key]; ok {
+			agg.Add("gauge", metric.NewMetric(name, value, metricTags))
+		}
+		if name, ok := rates[key]; ok {
+			agg.Add("rate", metric.NewMetric(name, value, metricTags))
+		}
+	}
+	return nil
+}
+
 func (m *Memcached) parseStats(conn io.Reader) (map[string]float64, error) {
 	scanner := bufio.NewScanner(conn)
 	stats := make(map[string]float64)
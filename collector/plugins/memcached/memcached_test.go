@@ -217,3 +217,95 @@ func TestCollectMetrics(t *testing.T) {
 		testutil.AssertContainsMetricWithTags(t, metrics, name, value, tags, 0.0001)
 	}
 }
+
+const (
+	slabsStub = `STAT 1:chunk_size 96
+STAT 1:chunks_per_page 10922
+STAT 1:total_pages 1
+STAT 1:total_chunks 10922
+STAT 1:used_chunks 5
+STAT 1:free_chunks 10917
+STAT 1:free_chunks_end 0
+STAT 1:mem_requested 403
+STAT 1:get_hits 13
+STAT 1:cmd_set 15
+STAT 1:delete_hits 0
+STAT 1:incr_hits 0
+STAT 1:decr_hits 0
+STAT 1:cas_hits 0
+STAT 1:cas_badval 0
+STAT 1:touch_hits 0
+STAT active_slabs 1
+STAT total_malloced 1048576
+END
+`
+
+	itemsStub = `STAT items:1:number 5
+STAT items:1:number_hot 0
+STAT items:1:number_warm 0
+STAT items:1:number_cold 5
+STAT items:1:age_hot 0
+STAT items:1:age_warm 0
+STAT items:1:age 1271
+STAT items:1:evicted 3
+STAT items:1:evicted_nonzero 0
+STAT items:1:evicted_time 120
+STAT items:1:outofmemory 1
+STAT items:1:tailrepairs 0
+STAT items:1:reclaimed 0
+STAT items:1:expired_unfetched 0
+STAT items:1:evicted_unfetched 0
+STAT items:1:crawler_reclaimed 0
+STAT items:1:crawler_items_checked 0
+STAT items:1:lrutail_reflocked 0
+END
+`
+)
+
+func TestCollectSlabsMetrics(t *testing.T) {
+	m := &Memcached{
+		Tags: []string{"service:memcached"},
+	}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	err := m.collectSlabsMetrics(bytes.NewBufferString(slabsStub), "", SLABS_GAUGES, SLABS_RATES, m.Tags, agg)
+	require.NoError(t, err)
+	err = m.collectSlabsMetrics(bytes.NewBufferString(itemsStub), "items:", ITEMS_GAUGES, ITEMS_RATES, m.Tags, agg)
+	require.NoError(t, err)
+	agg.Flush()
+	expectedMetrics := 13
+	require.Len(t, metricC, expectedMetrics)
+
+	metrics := make([]metric.Metric, expectedMetrics)
+	for i := 0; i < expectedMetrics; i++ {
+		metrics[i] = <-metricC
+	}
+
+	fields := map[string]float64{
+		"memcache.slabs.active_slabs":   1,
+		"memcache.slabs.total_malloced": 1048576,
+	}
+	for name, value := range fields {
+		testutil.AssertContainsMetricWithTags(t, metrics, name, value, m.Tags)
+	}
+
+	fields = map[string]float64{
+		"memcache.slabs.chunk_size":      96,
+		"memcache.slabs.chunks_per_page": 10922,
+		"memcache.slabs.total_pages":     1,
+		"memcache.slabs.total_chunks":    10922,
+		"memcache.slabs.used_chunks":     5,
+		"memcache.slabs.free_chunks":     10917,
+		"memcache.slabs.free_chunks_end": 0,
+		"memcache.slabs.mem_requested":   403,
+		"memcache.items.number":          5,
+		"memcache.items.age":             1271,
+		"memcache.items.evicted_time":    120,
+	}
+	tags := []string{"service:memcached", "slab:1"}
+	for name, value := range fields {
+		testutil.AssertContainsMetricWithTags(t, metrics, name, value, tags)
+	}
+}
@@ -28,6 +28,9 @@ type MongoDB struct {
 	Timeout           int64
 	Tags              []string
 	AdditionalMetrics []string `yaml:"additional_metrics"`
+	// The collections to collect collStats and $indexStats, which look like
+	// db.collection, defaults to all the collections.
+	Collections []string
 }
 
 // ReplSetStatus stores information from replSetGetStatus
@@ -72,6 +75,7 @@ func (s MongoSession) DB(name string) DataLayer {
 // DataLayer is an interface to access to the database struct.
 type DataLayer interface {
 	Run(cmd interface{}, result interface{}) error
+	CollectionNames() (names []string, err error)
 }
 
 const (
@@ -375,6 +379,25 @@ var (
 		"writeLock.time":  gauge,
 	}
 
+	// Storage statistics for each collection.
+	// https://docs.mongodb.com/manual/reference/command/collStats/
+	collStatsMetrics = map[string]string{
+		"avgObjSize":     gauge,
+		"count":          gauge,
+		"nindexes":       gauge,
+		"size":           gauge,
+		"storageSize":    gauge,
+		"totalIndexSize": gauge,
+	}
+
+	// The operations in progress, collected from currentOp.
+	// https://docs.mongodb.com/manual/reference/command/currentOp/
+	currentOpMetrics = map[string]string{
+		"currentop.count":            gauge,
+		"currentop.max_age":          gauge,
+		"currentop.waiting_for_lock": gauge,
+	}
+
 	// Mapping for case-sensitive metric name suffixes.
 	// https://docs.mongodb.org/manual/reference/command/serverStatus/#server-status-locks
 	caseSensitiveMetricNameSuffixes = map[string]string{
@@ -392,6 +415,8 @@ var (
 		"tcmalloc":         tcmallocMetrics,
 		"wiredtiger":       wiredtigerMetrics,
 		"top":              topMetrics,
+		"collection":       collStatsMetrics,
+		"currentop":        currentOpMetrics,
 	}
 )
 
@@ -512,6 +537,10 @@ func (m *MongoDB) collectServerStatus(session Session, tags []string, agg metric
 					topTags := append(tags, "db:"+dbname, "collection:"+collname)
 					m.submitMetrics(stats, val, topTags, agg)
 				}
+			} else if reflect.DeepEqual(val, collStatsMetrics) {
+				m.collectCollectionStats(session, tags, agg)
+			} else if reflect.DeepEqual(val, currentOpMetrics) {
+				m.collectCurrentOp(session, tags, agg)
 			} else {
 				m.submitMetrics(serverStatus, val, tags, agg)
 			}
@@ -555,6 +584,8 @@ func (m *MongoDB) submitMetrics(stats bson.M, metrics map[string]string, tags []
 			metricName = normalize(k, v, "usage")
 		} else if reflect.DeepEqual(metrics, dbStatsMetrics) {
 			metricName = normalize(k, v, "stats")
+		} else if reflect.DeepEqual(metrics, collStatsMetrics) {
+			metricName = normalize(k, v, "collection")
 		} else if reflect.DeepEqual(metrics, wiredtigerMetrics) {
 			metricName = normalize(strings.Replace(k, " ", "_", -1), v, "")
 		} else {
@@ -564,6 +595,200 @@ func (m *MongoDB) submitMetrics(stats bson.M, metrics map[string]string, tags []
 	}
 }
 
+// collectCollectionStats collects collStats and $indexStats of the configured
+// collections, or all the collections except the system ones.
+func (m *MongoDB) collectCollectionStats(session Session, tags []string, agg metric.Aggregator) {
+	collections, err := m.getCollections(session)
+	if err != nil {
+		log.Errorf("Failed to get collection names, %s", err.Error())
+		return
+	}
+
+	for _, ns := range collections {
+		split := strings.SplitN(ns, ".", 2)
+		if len(split) != 2 {
+			log.Warnf("Invalid collection %s, it should look like db.collection", ns)
+			continue
+		}
+		dbname, collname := split[0], split[1]
+		collTags := make([]string, 0, len(tags)+2)
+		collTags = append(collTags, tags...)
+		collTags = append(collTags, "db:"+dbname, "collection:"+collname)
+		db := session.DB(dbname)
+
+		collStats := bson.M{}
+		err := db.Run(bson.D{
+			{
+				Name:  "collStats",
+				Value: collname,
+			},
+		}, &collStats)
+		if err != nil {
+			log.Errorf("Failed to get stats of collection %s, %s", ns, err.Error())
+			continue
+		}
+		m.submitMetrics(collStats, collStatsMetrics, collTags, agg)
+
+		if indexSizes, ok := toMap(collStats["indexSizes"]); ok {
+			for index := range indexSizes {
+				val, err := getFloatValue(indexSizes, []string{index})
+				if err != nil {
+					continue
+				}
+				agg.Add(gauge, metric.NewMetric("mongodb.collection.indexsizes", val, append(collTags, "index:"+index)))
+			}
+		}
+
+		// Required version >= 3.2
+		indexStats := bson.M{}
+		err = db.Run(bson.D{
+			{
+				Name:  "aggregate",
+				Value: collname,
+			},
+			{
+				Name:  "pipeline",
+				Value: []bson.M{{"$indexStats": bson.M{}}},
+			},
+			{
+				Name:  "cursor",
+				Value: bson.M{},
+			},
+		}, &indexStats)
+		if err != nil {
+			log.Debugf("Failed to get index stats of collection %s, %s", ns, err.Error())
+			continue
+		}
+		cursor, _ := toMap(indexStats["cursor"])
+		batch, _ := cursor["firstBatch"].([]interface{})
+		for _, v := range batch {
+			index, ok := toMap(v)
+			if !ok {
+				continue
+			}
+			val, err := getFloatValue(index, []string{"accesses", "ops"})
+			if err != nil {
+				continue
+			}
+			name := fmt.Sprint(index["name"])
+			agg.Add(rate, metric.NewMetric("mongodb.collection.indexes.accessesps", val, append(collTags, "index:"+name)))
+		}
+	}
+}
+
+func (m *MongoDB) getCollections(session Session) ([]string, error) {
+	if len(m.Collections) > 0 {
+		return m.Collections, nil
+	}
+
+	names, err := session.DatabaseNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var collections []string
+	for _, name := range names {
+		collnames, err := session.DB(name).CollectionNames()
+		if err != nil {
+			return nil, err
+		}
+		for _, collname := range collnames {
+			if strings.HasPrefix(collname, "system.") {
+				continue
+			}
+			collections = append(collections, name+"."+collname)
+		}
+	}
+	return collections, nil
+}
+
+// collectCurrentOp collects the number and the max age of the active
+// operations for each collection.
+func (m *MongoDB) collectCurrentOp(session Session, tags []string, agg metric.Aggregator) {
+	result := bson.M{}
+	err := session.Run(bson.D{
+		{
+			Name:  "currentOp",
+			Value: 1,
+		},
+	}, &result)
+	if err != nil {
+		log.Errorf("Failed to get current operations, %s", err.Error())
+		return
+	}
+
+	type opStats struct {
+		count          int
+		maxAge         float64
+		waitingForLock int
+	}
+	stats := make(map[string]*opStats)
+
+	inprog, _ := result["inprog"].([]interface{})
+	for _, v := range inprog {
+		op, ok := toMap(v)
+		if !ok {
+			continue
+		}
+		if active, _ := op["active"].(bool); !active {
+			continue
+		}
+		// Skip the currentOp command run by the check itself.
+		if command, ok := toMap(op["command"]); ok {
+			if _, ok := command["currentOp"]; ok {
+				continue
+			}
+		}
+
+		ns, _ := op["ns"].(string)
+		s, ok := stats[ns]
+		if !ok {
+			s = &opStats{}
+			stats[ns] = s
+		}
+
+		s.count++
+		if waiting, _ := op["waitingForLock"].(bool); waiting {
+			s.waitingForLock++
+		}
+		age, err := getFloatValue(op, []string{"microsecs_running"})
+		if err == nil {
+			age /= 1e6
+		} else {
+			age, _ = getFloatValue(op, []string{"secs_running"})
+		}
+		if age > s.maxAge {
+			s.maxAge = age
+		}
+	}
+
+	for ns, s := range stats {
+		opTags := make([]string, 0, len(tags)+2)
+		opTags = append(opTags, tags...)
+		// The commands run on db.$cmd, and some operations have no namespace.
+		if split := strings.SplitN(ns, ".", 2); split[0] != "" {
+			opTags = append(opTags, "db:"+split[0])
+			if len(split) == 2 && split[1] != "$cmd" {
+				opTags = append(opTags, "collection:"+split[1])
+			}
+		}
+		agg.Add(gauge, metric.NewMetric("mongodb.currentop.count", s.count, opTags))
+		agg.Add(gauge, metric.NewMetric("mongodb.currentop.max_age", s.maxAge, opTags))
+		agg.Add(gauge, metric.NewMetric("mongodb.currentop.waiting_for_lock", s.waitingForLock, opTags))
+	}
+}
+
+// toMap converts the sub-document, which is a bson.M when decoded by mgo.
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case bson.M:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
 func getFloatValue(s map[string]interface{}, keys []string) (float64, error) {
 	var val float64
 	sm := s
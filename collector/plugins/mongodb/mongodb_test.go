@@ -10,6 +10,7 @@ import (
 
 	"github.com/cloudinsight/cloudinsight-agent/common"
 	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -61,6 +62,11 @@ func (m *MockDatabase) Run(cmd interface{}, result interface{}) error {
 	return nil
 }
 
+// CollectionNames mocks mgo.Database.CollectionNames().
+func (m *MockDatabase) CollectionNames() ([]string, error) {
+	return []string{"me", "startup_log", "system.indexes"}, nil
+}
+
 func TestCollectMetrics(t *testing.T) {
 	var err error
 	session := NewMockSession()
@@ -219,3 +225,97 @@ func TestCollectMetrics(t *testing.T) {
 		testutil.AssertContainsMetricWithTags(t, metrics, name, value, tags)
 	}
 }
+
+func TestCollectCollectionStats(t *testing.T) {
+	session := NewMockSession()
+	defer session.Close()
+
+	m := &MongoDB{
+		Tags:        []string{"service:mongodb"},
+		Collections: []string{"local.startup_log"},
+	}
+
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	m.collectCollectionStats(session, m.Tags, agg)
+	agg.Flush()
+	// The accesses of indexes are rates.
+	expectedMetrics := 8
+	require.Len(t, metricC, expectedMetrics)
+
+	metrics := make([]metric.Metric, expectedMetrics)
+	for i := 0; i < expectedMetrics; i++ {
+		metrics[i] = <-metricC
+	}
+
+	fields := map[string]float64{
+		"mongodb.collection.avgobjsize":     1271,
+		"mongodb.collection.count":          6,
+		"mongodb.collection.nindexes":       2,
+		"mongodb.collection.size":           7626,
+		"mongodb.collection.storagesize":    36864,
+		"mongodb.collection.totalindexsize": 53248,
+	}
+	tags := []string{"service:mongodb", "db:local", "collection:startup_log"}
+	for name, value := range fields {
+		testutil.AssertContainsMetricWithTags(t, metrics, name, value, tags)
+	}
+
+	tags = []string{"service:mongodb", "db:local", "collection:startup_log", "index:_id_"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.collection.indexsizes", 36864, tags)
+	tags = []string{"service:mongodb", "db:local", "collection:startup_log", "index:hostname_1"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.collection.indexsizes", 16384, tags)
+}
+
+func TestGetCollections(t *testing.T) {
+	session := NewMockSession()
+	defer session.Close()
+
+	m := &MongoDB{}
+	collections, err := m.getCollections(session)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"local.me", "local.startup_log"}, collections)
+
+	m.Collections = []string{"app.users"}
+	collections, err = m.getCollections(session)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app.users"}, collections)
+}
+
+func TestCollectCurrentOp(t *testing.T) {
+	session := NewMockSession()
+	defer session.Close()
+
+	m := &MongoDB{
+		Tags: []string{"service:mongodb"},
+	}
+
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	m.collectCurrentOp(session, m.Tags, agg)
+	agg.Flush()
+	expectedMetrics := 9
+	require.Len(t, metricC, expectedMetrics)
+
+	metrics := make([]metric.Metric, expectedMetrics)
+	for i := 0; i < expectedMetrics; i++ {
+		metrics[i] = <-metricC
+	}
+
+	tags := []string{"service:mongodb", "db:app", "collection:orders"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.currentop.count", 2, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.currentop.max_age", 12.5, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.currentop.waiting_for_lock", 1, tags)
+
+	tags = []string{"service:mongodb", "db:app"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.currentop.count", 1, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.currentop.max_age", 3, tags)
+
+	tags = []string{"service:mongodb"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.currentop.count", 1, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "mongodb.currentop.waiting_for_lock", 0, tags)
+}
@@ -6,11 +6,13 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/cloudinsight/cloudinsight-agent/collector"
 	"github.com/cloudinsight/cloudinsight-agent/common/log"
 	"github.com/cloudinsight/cloudinsight-agent/common/metric"
 	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+	"github.com/cloudinsight/cloudinsight-agent/common/util"
 	"github.com/go-sql-driver/mysql"
 )
 
@@ -21,9 +23,10 @@ func NewMySQL(conf plugin.InitConfig) plugin.Plugin {
 
 // MySQL XXX
 type MySQL struct {
-	Server  string
-	Tags    []string
-	Options Options
+	Server        string
+	Tags          []string
+	Options       Options
+	CustomQueries []CustomQuery `yaml:"custom_queries"`
 
 	logBinEnabled            bool
 	performanceSchemaEnabled bool
@@ -38,6 +41,18 @@ type Options struct {
 	ExtraPerformanceMetrics bool `yaml:"extra_performance_metrics"`
 	SchemaSizeMetrics       bool `yaml:"schema_size_metrics"`
 	DisableInnodbMetrics    bool `yaml:"diable_innodb_metrics"`
+	StatementDigestMetrics  bool `yaml:"statement_digest_metrics"`
+	StatementDigestLimit    int  `yaml:"statement_digest_limit"`
+	TableIOMetrics          bool `yaml:"table_io_metrics"`
+}
+
+// CustomQuery maps the columns of the rows returned by the query to metrics
+// and tags.
+type CustomQuery struct {
+	Query   string
+	Metrics map[string]metric.Field
+	Tags    map[string]string
+	Limit   int
 }
 
 const (
@@ -65,6 +80,28 @@ const (
 		  FROM information_schema.tables
 		 GROUP BY table_schema;
 	`
+	statementDigestQuery = `
+        SELECT schema_name, digest, digest_text, count_star,
+               sum_timer_wait, sum_rows_examined, sum_errors
+		  FROM performance_schema.events_statements_summary_by_digest
+		 WHERE digest_text IS NOT NULL
+		 ORDER BY sum_timer_wait DESC
+		 LIMIT ?
+	`
+	tableIOWaitsQuery = `
+        SELECT object_schema, object_name,
+               count_read, sum_timer_read, count_write, sum_timer_write
+		  FROM performance_schema.table_io_waits_summary_by_table
+		 WHERE object_schema NOT IN ('mysql', 'performance_schema', 'information_schema', 'sys')
+	`
+
+	maxCustomResults = 100
+
+	defaultDigestLimit  = 50
+	maxDigestTextLength = 200
+
+	// The timers of performance_schema are in picoseconds
+	picosecondsPerNanosecond = 1000
 )
 
 var (
@@ -301,6 +338,23 @@ var (
 		"perf_digest_95th_percentile_avg_us": {"mysql.performance.digest_95th_percentile.avg_us", gauge},
 	}
 
+	// Columns of events_statements_summary_by_digest, the timers are
+	// converted from picoseconds to nanoseconds.
+	digestVars = map[string]metric.Field{
+		"count_star":        {"mysql.queries.count", rate},
+		"sum_timer_wait":    {"mysql.queries.time", rate},
+		"sum_rows_examined": {"mysql.queries.rows_examined", rate},
+		"sum_errors":        {"mysql.queries.errors", rate},
+	}
+
+	// Columns of table_io_waits_summary_by_table
+	tableIOVars = map[string]metric.Field{
+		"count_read":      {"mysql.table.io.reads", rate},
+		"sum_timer_read":  {"mysql.table.io.read_time", rate},
+		"count_write":     {"mysql.table.io.writes", rate},
+		"sum_timer_write": {"mysql.table.io.write_time", rate},
+	}
+
 	schemaVars = map[string]metric.Field{
 		"information_schema_size": {"mysql.info.schema.size", gauge},
 	}
@@ -310,6 +364,15 @@ var (
 		"Slaves_connected":      {"mysql.replication.slaves_connected", count},
 	}
 
+	// The types of metrics allowed in custom queries
+	customMetricTypes = map[string]string{
+		"gauge":          gauge,
+		"rate":           rate,
+		"count":          count,
+		"monotonic":      monotonic,
+		"monotoniccount": monotonic,
+	}
+
 	syntheticVars = map[string]metric.Field{
 		"Qcache_utilization":         {"mysql.performance.qcache.utilization", gauge},
 		"Qcache_instant_utilization": {"mysql.performance.qcache.utilization.instant", gauge},
@@ -445,6 +508,22 @@ func (m *MySQL) collectMetrics(db *sql.DB, agg metric.Aggregator) error {
 		// TODO
 		metric.UpdateMap(metrics, performanceVars)
 	}
+	if m.Options.StatementDigestMetrics && m.performanceSchemaEnabled {
+		log.Debug("Collecting Statement Digest Metrics.")
+		// The metrics already collected are still submitted when the
+		// performance_schema tables aren't accessible.
+		if err := m.collectStatementDigests(db, agg); err != nil {
+			log.Warnf("Failed to collect statement digest metrics: %s", err)
+		}
+	}
+	if m.Options.TableIOMetrics && m.performanceSchemaEnabled {
+		log.Debug("Collecting Table IO Metrics.")
+		// The metrics already collected are still submitted when the
+		// performance_schema tables aren't accessible.
+		if err := m.collectTableIOWaits(db, agg); err != nil {
+			log.Warnf("Failed to collect table IO metrics: %s", err)
+		}
+	}
 	if m.Options.SchemaSizeMetrics {
 		log.Debug("Collecting Schema Size Metrics.")
 		err = m.collectTableSchema(db, agg)
@@ -460,6 +539,12 @@ func (m *MySQL) collectMetrics(db *sql.DB, agg metric.Aggregator) error {
 
 	m.submitMetrics(fields, metrics, agg)
 
+	for _, q := range m.CustomQueries {
+		if err := m.collectCustomQuery(db, q, agg); err != nil {
+			log.Warnf("Failed to collect custom query %s: %s", q.Query, err)
+		}
+	}
+
 	return nil
 }
 
@@ -616,6 +701,181 @@ func (m *MySQL) collectTableSchema(db *sql.DB, agg metric.Aggregator) error {
 	return nil
 }
 
+// collectStatementDigests collects the top statements by total latency. The
+// statistics are cumulative since the server started or the summary table
+// was truncated.
+func (m *MySQL) collectStatementDigests(db *sql.DB, agg metric.Aggregator) error {
+	limit := m.Options.StatementDigestLimit
+	if limit <= 0 {
+		limit = defaultDigestLimit
+	}
+
+	rows, err := db.Query(statementDigestQuery, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var schema sql.NullString
+	var digest, digestText string
+	var countStar, sumTimerWait, sumRowsExamined, sumErrors float64
+
+	for rows.Next() {
+		err = rows.Scan(&schema, &digest, &digestText, &countStar, &sumTimerWait, &sumRowsExamined, &sumErrors)
+		if err != nil {
+			return err
+		}
+
+		schemaName := schema.String
+		if schemaName == "" {
+			schemaName = "none"
+		}
+		tags := make([]string, 0, len(m.Tags)+3)
+		tags = append(tags, m.Tags...)
+		tags = append(tags,
+			"schema:"+schemaName,
+			"query_signature:"+digest,
+			"query:"+normalizeDigestText(digestText),
+		)
+
+		values := map[string]float64{
+			"count_star":        countStar,
+			"sum_timer_wait":    sumTimerWait / picosecondsPerNanosecond,
+			"sum_rows_examined": sumRowsExamined,
+			"sum_errors":        sumErrors,
+		}
+		for col, value := range values {
+			field := digestVars[col]
+			agg.Add(field.Type, metric.NewMetric(field.Name, value, tags))
+		}
+	}
+
+	return rows.Err()
+}
+
+func (m *MySQL) collectTableIOWaits(db *sql.DB, agg metric.Aggregator) error {
+	rows, err := db.Query(tableIOWaitsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var schema, table string
+	var countRead, sumTimerRead, countWrite, sumTimerWrite float64
+
+	for rows.Next() {
+		err = rows.Scan(&schema, &table, &countRead, &sumTimerRead, &countWrite, &sumTimerWrite)
+		if err != nil {
+			return err
+		}
+
+		tags := make([]string, 0, len(m.Tags)+2)
+		tags = append(tags, m.Tags...)
+		tags = append(tags, "schema:"+schema, "table:"+table)
+
+		values := map[string]float64{
+			"count_read":      countRead,
+			"sum_timer_read":  sumTimerRead / picosecondsPerNanosecond,
+			"count_write":     countWrite,
+			"sum_timer_write": sumTimerWrite / picosecondsPerNanosecond,
+		}
+		for col, value := range values {
+			field := tableIOVars[col]
+			agg.Add(field.Type, metric.NewMetric(field.Name, value, tags))
+		}
+	}
+
+	return rows.Err()
+}
+
+// normalizeDigestText collapses the whitespaces of the digest text, in which
+// the literals are already replaced by "?", and truncates it to be used as a
+// tag.
+func normalizeDigestText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > maxDigestTextLength {
+		// Cut on a rune boundary to keep the tag valid UTF-8.
+		cut := maxDigestTextLength - 3
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "..."
+	}
+	return text
+}
+
+func (m *MySQL) collectCustomQuery(db *sql.DB, q CustomQuery, agg metric.Aggregator) error {
+	for col, field := range q.Metrics {
+		if _, ok := customMetricTypes[field.Type]; !ok {
+			log.Errorf("Metric type %s of column %s is not known. Known types are gauge, rate, count, monotonic", field.Type, col)
+			delete(q.Metrics, col)
+		}
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = maxCustomResults
+	}
+
+	log.Debugf("Running custom query: %s", q.Query)
+	rows, err := db.Query(q.Query)
+	if err != nil {
+		log.Errorf("Failed to execute custom query. %s", q.Query)
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for col := range q.Metrics {
+		if !util.StringInSlice(col, columns) {
+			return fmt.Errorf("Column %s is not found in the result of custom query: %s", col, q.Query)
+		}
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	rowsCount := 0
+	for rows.Next() {
+		rowsCount++
+		if rowsCount > limit {
+			log.Warnf("Custom query: %s returned more than %d results. Truncating", q.Query, limit)
+			break
+		}
+
+		err = rows.Scan(valuePtrs...)
+		if err != nil {
+			return err
+		}
+
+		tags := make([]string, 0, len(m.Tags)+len(q.Tags))
+		tags = append(tags, m.Tags...)
+		for i, col := range columns {
+			if tag, ok := q.Tags[col]; ok && values[i] != nil {
+				tags = append(tags, tag+":"+string(values[i]))
+			}
+		}
+
+		for i, col := range columns {
+			field, ok := q.Metrics[col]
+			if !ok || values[i] == nil {
+				continue
+			}
+			if value, ok := parseValue(values[i]); ok {
+				agg.Add(customMetricTypes[field.Type], metric.NewMetric(field.Name, value, tags))
+			}
+		}
+	}
+
+	return rows.Err()
+}
+
 func (m *MySQL) isInnodbEnabled(db *sql.DB) bool {
 	// run query
 	var engine string
@@ -2,7 +2,10 @@ package mysql
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/cloudinsight/cloudinsight-agent/common"
 	"github.com/cloudinsight/cloudinsight-agent/common/metric"
@@ -345,10 +348,28 @@ func TestCollectMetrics(t *testing.T) {
 	mock.ExpectQuery("^SHOW (.+) ENGINE (.+) INNODB STATUS$").WillReturnRows(innodbStatusResult)
 	mock.ExpectQuery(globalVariablesQuery).WillReturnRows(globalVariablesResult)
 	mock.ExpectQuery(binaryLogsQuery).WillReturnRows(binaryLogsResult)
+	// Missing grants on performance_schema don't drop the other metrics.
+	denied := fmt.Errorf("SELECT command denied to user")
+	mock.ExpectQuery("SELECT (.+) FROM performance_schema.events_statements_summary_by_digest (.+)").WillReturnError(denied)
+	mock.ExpectQuery("SELECT (.+) FROM performance_schema.table_io_waits_summary_by_table (.+)").WillReturnError(denied)
 	mock.ExpectQuery("SELECT table_schema, (.+)total_mb FROM information_schema.tables (.+)").WillReturnRows(tableSchemaResult)
+	// A failing custom query doesn't skip the next ones.
+	mock.ExpectQuery("SELECT (.+) FROM shop.orders").WillReturnError(denied)
+	mock.ExpectQuery("SELECT (.+) FROM queue.jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"jobs"}).AddRow(5))
 
 	m := &MySQL{
 		Tags: []string{"env:production"},
+		CustomQueries: []CustomQuery{
+			{
+				Query:   "SELECT COUNT(*) AS orders FROM shop.orders",
+				Metrics: map[string]metric.Field{"orders": {Name: "shop.orders.count", Type: "gauge"}},
+			},
+			{
+				Query:   "SELECT COUNT(*) AS jobs FROM queue.jobs",
+				Metrics: map[string]metric.Field{"jobs": {Name: "queue.jobs", Type: "gauge"}},
+			},
+		},
 		Options: Options{
 			Replication:             true,
 			GaleraCluster:           true,
@@ -356,6 +377,8 @@ func TestCollectMetrics(t *testing.T) {
 			ExtraInnodbMetrics:      true,
 			ExtraPerformanceMetrics: true,
 			SchemaSizeMetrics:       true,
+			StatementDigestMetrics:  true,
+			TableIOMetrics:          true,
 			DisableInnodbMetrics:    false,
 		},
 	}
@@ -366,7 +389,7 @@ func TestCollectMetrics(t *testing.T) {
 	err = m.collectMetrics(db, agg)
 	require.NoError(t, err)
 	agg.Flush()
-	expectedMetrics := 68
+	expectedMetrics := 69
 	require.Len(t, metricC, expectedMetrics)
 
 	metrics := make([]metric.Metric, expectedMetrics)
@@ -454,6 +477,197 @@ func TestCollectMetrics(t *testing.T) {
 	}
 }
 
+func TestCollectCustomQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := "SELECT status, COUNT(*) AS orders, SUM(amount) AS amount FROM shop.orders GROUP BY status"
+	rows := sqlmock.NewRows([]string{"status", "orders", "amount"}).
+		AddRow("paid", 12, 1024.5).
+		AddRow("pending", 3, nil).
+		AddRow("refunded", 1, 10)
+	mock.ExpectQuery("SELECT status, (.+) FROM shop.orders GROUP BY status").WillReturnRows(rows)
+
+	m := &MySQL{
+		Tags: []string{"env:production"},
+	}
+	q := CustomQuery{
+		Query: query,
+		Metrics: map[string]metric.Field{
+			"orders": {Name: "shop.orders.count", Type: "gauge"},
+			"amount": {Name: "shop.orders.amount", Type: "gauge"},
+		},
+		Tags: map[string]string{
+			"status": "order_status",
+		},
+		Limit: 2,
+	}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	err = m.collectCustomQuery(db, q, agg)
+	require.NoError(t, err)
+	agg.Flush()
+
+	// The third row is over the limit, the NULL amount is skipped.
+	require.Len(t, metricC, 3)
+	metrics := make([]metric.Metric, 3)
+	for i := range metrics {
+		metrics[i] = <-metricC
+	}
+	tags := []string{"env:production", "order_status:paid"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "shop.orders.count", 12, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "shop.orders.amount", 1024.5, tags)
+	tags = []string{"env:production", "order_status:pending"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "shop.orders.count", 3, tags)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCollectCustomQueryInvalid(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m := &MySQL{}
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	// The column of an unknown type is dropped.
+	mock.ExpectQuery("SELECT (.+) FROM queue.jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"jobs", "oldest"}).AddRow(5, 60))
+	q := CustomQuery{
+		Query: "SELECT COUNT(*) AS jobs, MAX(age) AS oldest FROM queue.jobs",
+		Metrics: map[string]metric.Field{
+			"jobs":   {Name: "queue.jobs", Type: "histogram"},
+			"oldest": {Name: "queue.jobs.oldest", Type: "gauge"},
+		},
+	}
+	err = m.collectCustomQuery(db, q, agg)
+	require.NoError(t, err)
+	agg.Flush()
+	require.Len(t, metricC, 1)
+	assert.Equal(t, "queue.jobs.oldest", (<-metricC).Name)
+
+	mock.ExpectQuery("SELECT (.+) FROM queue.jobs").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(5))
+	q = CustomQuery{
+		Query: "SELECT COUNT(*) FROM queue.jobs",
+		Metrics: map[string]metric.Field{
+			"jobs": {Name: "queue.jobs", Type: "gauge"},
+		},
+	}
+	err = m.collectCustomQuery(db, q, agg)
+	assert.EqualError(t, err, "Column jobs is not found in the result of custom query: SELECT COUNT(*) FROM queue.jobs")
+}
+
+func TestCollectStatementDigests(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{
+		"schema_name", "digest", "digest_text", "count_star",
+		"sum_timer_wait", "sum_rows_examined", "sum_errors",
+	}).
+		AddRow("shop", "4cd6b7ce", "SELECT * FROM `orders`\n  WHERE `id` = ? ", 120, 36000000000, 1200, 2).
+		AddRow(nil, "8a3ec6a1", "SHOW VARIABLES", 10, 5000000, 10, 0)
+	mock.ExpectQuery("SELECT (.+) FROM performance_schema.events_statements_summary_by_digest (.+)").
+		WithArgs(10).
+		WillReturnRows(rows)
+
+	m := &MySQL{
+		Tags: []string{"env:production"},
+		Options: Options{
+			StatementDigestLimit: 10,
+		},
+	}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	err = m.collectStatementDigests(db, agg)
+	require.NoError(t, err)
+	// Rates need two samples.
+	assert.Len(t, metricC, 0)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCollectStatementDigestsWithRates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{
+		"schema_name", "digest", "digest_text", "count_star",
+		"sum_timer_wait", "sum_rows_examined", "sum_errors",
+	}
+	text := "SELECT * FROM `orders`\n  WHERE `id` = ? "
+	mock.ExpectQuery("SELECT (.+) FROM performance_schema.events_statements_summary_by_digest (.+)").
+		WithArgs(defaultDigestLimit).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("shop", "4cd6b7ce", text, 120, 36000000000, 1200, 2))
+	mock.ExpectQuery("SELECT (.+) FROM performance_schema.events_statements_summary_by_digest (.+)").
+		WithArgs(defaultDigestLimit).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("shop", "4cd6b7ce", text, 130, 39000000000, 1300, 2))
+
+	m := &MySQL{}
+	fields := map[string]float64{
+		"mysql.queries.count":         10,
+		"mysql.queries.time":          3000000,
+		"mysql.queries.rows_examined": 100,
+		"mysql.queries.errors":        0,
+	}
+	tags := []string{"schema:shop", "query_signature:4cd6b7ce", "query:SELECT * FROM `orders` WHERE `id` = ?"}
+	check := func(agg metric.Aggregator) error {
+		return m.collectStatementDigests(db, agg)
+	}
+	testutil.AssertCheckWithRateMetrics(t, check, check, 4, fields, tags, 1)
+}
+
+func TestCollectTableIOWaits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{"object_schema", "object_name", "count_read", "sum_timer_read", "count_write", "sum_timer_write"}
+	mock.ExpectQuery("SELECT (.+) FROM performance_schema.table_io_waits_summary_by_table (.+)").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("shop", "orders", 100, 2000000, 10, 1000000))
+	mock.ExpectQuery("SELECT (.+) FROM performance_schema.table_io_waits_summary_by_table (.+)").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("shop", "orders", 150, 4000000, 20, 3000000))
+
+	m := &MySQL{
+		Tags: []string{"env:production"},
+	}
+	fields := map[string]float64{
+		"mysql.table.io.reads":      50,
+		"mysql.table.io.read_time":  2000,
+		"mysql.table.io.writes":     10,
+		"mysql.table.io.write_time": 2000,
+	}
+	tags := []string{"env:production", "schema:shop", "table:orders"}
+	check := func(agg metric.Aggregator) error {
+		return m.collectTableIOWaits(db, agg)
+	}
+	testutil.AssertCheckWithRateMetrics(t, check, check, 4, fields, tags, 1)
+}
+
+func TestNormalizeDigestText(t *testing.T) {
+	assert.Equal(t, "SELECT * FROM `t` WHERE `id` = ?", normalizeDigestText("SELECT *\n FROM `t`  WHERE `id` = ? "))
+
+	long := normalizeDigestText("SELECT " + strings.Repeat("`c`, ", 100) + "`d` FROM `t`")
+	assert.Len(t, long, maxDigestTextLength)
+	assert.True(t, strings.HasSuffix(long, "..."))
+
+	// The cut falls in the middle of a 3-byte rune.
+	multibyte := normalizeDigestText("SELECT `" + strings.Repeat("列", 100) + "` FROM `t`")
+	assert.True(t, utf8.ValidString(multibyte))
+	assert.True(t, len(multibyte) <= maxDigestTextLength)
+	assert.True(t, strings.HasSuffix(multibyte, "..."))
+}
+
 func TestParseInnodbStatus55(t *testing.T) {
 	stat := make(map[string]float64)
 
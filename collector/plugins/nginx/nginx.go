@@ -2,7 +2,9 @@ package nginx
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -41,18 +43,38 @@ func (n *Nginx) Check(agg metric.Aggregator) error {
 		return fmt.Errorf("Unable to parse address '%s': %s", n.NginxStatusURL, err)
 	}
 
-	resp, err := client.Get(addr.String())
+	body, err := n.get(addr.String())
 	if err != nil {
-		return fmt.Errorf("error making HTTP request to %s: %s", addr.String(), err)
+		return err
+	}
+
+	// The Nginx Plus API returns its versions, e.g. [1,2,3], and the VTS
+	// module returns an object.
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return n.collectPlusMetrics(addr.String(), trimmed, agg)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return n.collectVTSMetrics(trimmed, agg)
+	}
+	return n.collectStubStatus(bufio.NewReader(bytes.NewReader(body)), agg)
+}
+
+func (n *Nginx) get(requestURI string) ([]byte, error) {
+	resp, err := client.Get(requestURI)
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request to %s: %s", requestURI, err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("%s returned HTTP status %s", addr.String(), resp.Status)
+		return nil, fmt.Errorf("%s returned HTTP status %s", requestURI, resp.Status)
 	}
-	r := bufio.NewReader(resp.Body)
+	return ioutil.ReadAll(resp.Body)
+}
 
+func (n *Nginx) collectStubStatus(r *bufio.Reader, agg metric.Aggregator) error {
 	// Active connections
-	_, err = r.ReadString(':')
+	_, err := r.ReadString(':')
 	if err != nil {
 		return err
 	}
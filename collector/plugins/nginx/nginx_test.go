@@ -96,3 +96,109 @@ func TestNginxCheck(t *testing.T) {
 	}
 	testutil.AssertCheckWithRateMetrics(t, nt.Check, nt2.Check, 7, tengineFields, nt.Tags)
 }
+
+// The API also lists a version newer than maxPlusAPIVersion, which isn't
+// requested.
+var plusResponses = map[string]string{
+	"/api":                     `[1,2,3,4,5,6,7,8,9]`,
+	"/api/8/connections":       `{"accepted":4968119,"dropped":10,"active":5,"idle":117}`,
+	"/api/8/http/requests":     `{"total":10624511,"current":4}`,
+	"/api/8/http/server_zones": `{"hg.nginx.org":{"processing":1,"requests":175276,"responses":{"1xx":0,"2xx":162948,"3xx":10117,"4xx":2125,"5xx":86,"codes":{"200":162948,"301":10117,"404":2125,"503":86},"total":175276},"discarded":0,"received":47356338,"sent":6071651488}}`,
+	"/api/8/http/upstreams":    `{"trac-backend":{"peers":[{"id":0,"server":"10.0.0.1:8080","name":"10.0.0.1:8080","backup":false,"weight":1,"state":"up","active":0,"requests":103252,"header_time":118,"response_time":120,"responses":{"1xx":0,"2xx":99381,"3xx":3771,"4xx":88,"5xx":12,"codes":{"200":99381,"302":3771,"404":88,"502":12},"total":103252},"sent":56219264,"received":3043468012,"fails":0,"unavail":0,"health_checks":{"checks":20016,"fails":0,"unhealthy":0,"last_passed":true},"downtime":0},{"id":1,"server":"10.0.0.2:8080","name":"10.0.0.2:8080","backup":true,"weight":1,"state":"unhealthy","active":0,"requests":0,"responses":{"1xx":0,"2xx":0,"3xx":0,"4xx":0,"5xx":0,"codes":{},"total":0},"sent":0,"received":0,"fails":3,"unavail":1,"health_checks":{"checks":20016,"fails":20016,"unhealthy":1,"last_passed":false},"downtime":200160000}],"keepalive":0,"zombies":0,"zone":"trac-backend"}}`,
+}
+
+const vtsSampleResponse = `{"hostName":"localhost","nginxVersion":"1.13.12","connections":{"active":3,"reading":0,"writing":1,"waiting":2,"accepted":1500,"handled":1490,"requests":2500},"serverZones":{"localhost":{"requestCounter":2400,"inBytes":480000,"outBytes":9600000,"responses":{"1xx":0,"2xx":2300,"3xx":50,"4xx":40,"5xx":10,"miss":0,"bypass":0,"expired":0,"stale":0,"updating":0,"revalidated":0,"hit":0,"scarce":0},"requestMsec":12},"*":{"requestCounter":2400,"inBytes":480000,"outBytes":9600000,"responses":{"1xx":0,"2xx":2300,"3xx":50,"4xx":40,"5xx":10},"requestMsec":12}},"upstreamZones":{"backend":[{"server":"127.0.0.1:8080","requestCounter":1200,"inBytes":240000,"outBytes":4800000,"responses":{"1xx":0,"2xx":1190,"3xx":0,"4xx":5,"5xx":5},"requestMsec":10,"responseMsec":9,"weight":1,"maxFails":1,"failTimeout":10,"backup":false,"down":false},{"server":"127.0.0.1:8081","requestCounter":0,"inBytes":0,"outBytes":0,"responses":{"1xx":0,"2xx":0,"3xx":0,"4xx":0,"5xx":0},"requestMsec":0,"responseMsec":0,"weight":1,"maxFails":1,"failTimeout":10,"backup":false,"down":true}]}}`
+
+func TestNginxPlusCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rsp, ok := plusResponses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	n := &Nginx{
+		NginxStatusURL: fmt.Sprintf("%s/api", ts.URL),
+		Tags:           []string{"service:nginx"},
+	}
+
+	fields := map[string]float64{
+		"nginx.net.connections": 5,
+		"nginx.net.waiting":     117,
+	}
+	testutil.AssertCheckWithMetrics(t, n.Check, 9, fields, n.Tags)
+
+	fields = map[string]float64{
+		"nginx.server_zone.processing": 1,
+	}
+	tags := []string{"service:nginx", "server_zone:hg.nginx.org"}
+	testutil.AssertCheckWithMetrics(t, n.Check, 9, fields, tags)
+
+	fields = map[string]float64{
+		"nginx.upstream.peer.up":            1,
+		"nginx.upstream.peer.active":        0,
+		"nginx.upstream.peer.response_time": 120,
+		"nginx.upstream.peer.header_time":   118,
+	}
+	tags = []string{"service:nginx", "upstream:trac-backend", "upstream_peer:10.0.0.1:8080"}
+	testutil.AssertCheckWithMetrics(t, n.Check, 9, fields, tags)
+
+	fields = map[string]float64{
+		"nginx.upstream.peer.up":     0,
+		"nginx.upstream.peer.active": 0,
+	}
+	tags = []string{"service:nginx", "upstream:trac-backend", "upstream_peer:10.0.0.2:8080"}
+	testutil.AssertCheckWithMetrics(t, n.Check, 9, fields, tags)
+
+	// The counters are reported as rates.
+	fields = map[string]float64{
+		"nginx.upstream.peer.requests":            0,
+		"nginx.upstream.peer.responses.5xx":       0,
+		"nginx.upstream.peer.fails":               0,
+		"nginx.upstream.peer.health_checks.fails": 0,
+	}
+	testutil.AssertCheckWithRateMetrics(t, n.Check, n.Check, 47, fields, tags)
+}
+
+func TestNginxVTSCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, vtsSampleResponse)
+	}))
+	defer ts.Close()
+
+	n := &Nginx{
+		NginxStatusURL: fmt.Sprintf("%s/status/format/json", ts.URL),
+		Tags:           []string{"service:nginx"},
+	}
+
+	fields := map[string]float64{
+		"nginx.net.connections": 3,
+		"nginx.net.reading":     0,
+		"nginx.net.writing":     1,
+		"nginx.net.waiting":     2,
+	}
+	testutil.AssertCheckWithMetrics(t, n.Check, 11, fields, n.Tags)
+
+	fields = map[string]float64{
+		"nginx.server_zone.request_time": 12,
+	}
+	tags := []string{"service:nginx", "server_zone:localhost"}
+	testutil.AssertCheckWithMetrics(t, n.Check, 11, fields, tags)
+
+	fields = map[string]float64{
+		"nginx.upstream.peer.up":            1,
+		"nginx.upstream.peer.request_time":  10,
+		"nginx.upstream.peer.response_time": 9,
+	}
+	tags = []string{"service:nginx", "upstream:backend", "upstream_peer:127.0.0.1:8080"}
+	testutil.AssertCheckWithMetrics(t, n.Check, 11, fields, tags)
+
+	fields = map[string]float64{
+		"nginx.upstream.peer.up": 0,
+	}
+	tags = []string{"service:nginx", "upstream:backend", "upstream_peer:127.0.0.1:8081"}
+	testutil.AssertCheckWithMetrics(t, n.Check, 11, fields, tags)
+}
@@ -0,0 +1,207 @@
+package nginx
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+// The response code classes of the Nginx Plus API and the VTS module.
+var responseClasses = []string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// maxPlusAPIVersion is the latest version of the Nginx Plus API which is
+// known to be decoded here.
+const maxPlusAPIVersion = 8
+
+type plusConnections struct {
+	Accepted int64 `json:"accepted"`
+	Dropped  int64 `json:"dropped"`
+	Active   int64 `json:"active"`
+	Idle     int64 `json:"idle"`
+}
+
+type plusRequests struct {
+	Total int64 `json:"total"`
+}
+
+// plusResponseCounts are the counts of responses by class, the counts by code
+// which are nested in them since version 8 are ignored.
+type plusResponseCounts struct {
+	Class1xx int64 `json:"1xx"`
+	Class2xx int64 `json:"2xx"`
+	Class3xx int64 `json:"3xx"`
+	Class4xx int64 `json:"4xx"`
+	Class5xx int64 `json:"5xx"`
+}
+
+func (r plusResponseCounts) byClass() map[string]int64 {
+	return map[string]int64{
+		"1xx": r.Class1xx,
+		"2xx": r.Class2xx,
+		"3xx": r.Class3xx,
+		"4xx": r.Class4xx,
+		"5xx": r.Class5xx,
+	}
+}
+
+type plusServerZone struct {
+	Processing int64              `json:"processing"`
+	Requests   int64              `json:"requests"`
+	Responses  plusResponseCounts `json:"responses"`
+	Discarded  int64              `json:"discarded"`
+	Received   int64              `json:"received"`
+	Sent       int64              `json:"sent"`
+}
+
+type plusUpstream struct {
+	Peers []plusPeer `json:"peers"`
+}
+
+type plusPeer struct {
+	Server       string             `json:"server"`
+	State        string             `json:"state"`
+	Active       int64              `json:"active"`
+	Requests     int64              `json:"requests"`
+	Responses    plusResponseCounts `json:"responses"`
+	Sent         int64              `json:"sent"`
+	Received     int64              `json:"received"`
+	Fails        int64              `json:"fails"`
+	Unavail      int64              `json:"unavail"`
+	HealthChecks struct {
+		Checks    int64 `json:"checks"`
+		Fails     int64 `json:"fails"`
+		Unhealthy int64 `json:"unhealthy"`
+	} `json:"health_checks"`
+	// The average response and header times in milliseconds, they are
+	// missing when the peer hasn't served any request.
+	ResponseTime *float64 `json:"response_time"`
+	HeaderTime   *float64 `json:"header_time"`
+}
+
+// collectPlusMetrics collects the metrics of the latest version of the Nginx
+// Plus API up to maxPlusAPIVersion, e.g. http://localhost/api whose root
+// lists the versions.
+func (n *Nginx) collectPlusMetrics(base string, versionsBody []byte, agg metric.Aggregator) error {
+	var versions []int
+	if err := json.Unmarshal(versionsBody, &versions); err != nil {
+		return fmt.Errorf("Unable to decode the Nginx Plus API versions: %s", err)
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("No version found in the Nginx Plus API %s", base)
+	}
+	version := 0
+	for _, v := range versions {
+		if v > version && v <= maxPlusAPIVersion {
+			version = v
+		}
+	}
+	if version == 0 {
+		return fmt.Errorf("No supported version found in the Nginx Plus API %s, the latest supported version is %d", base, maxPlusAPIVersion)
+	}
+	base = fmt.Sprintf("%s/%d", strings.TrimSuffix(base, "/"), version)
+
+	var conns plusConnections
+	if err := n.getJSON(base+"/connections", &conns); err != nil {
+		return err
+	}
+	var requests plusRequests
+	if err := n.getJSON(base+"/http/requests", &requests); err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"connections": conns.Active,
+		"waiting":     conns.Idle,
+	}
+	agg.AddMetrics("gauge", "nginx.net", fields, n.Tags, "")
+
+	fields = map[string]interface{}{
+		"conn_opened_per_s":  conns.Accepted,
+		"conn_dropped_per_s": conns.Dropped,
+		"request_per_s":      requests.Total,
+	}
+	agg.AddMetrics("rate", "nginx.net", fields, n.Tags, "")
+
+	var zones map[string]plusServerZone
+	if err := n.getJSON(base+"/http/server_zones", &zones); err != nil {
+		return err
+	}
+	for name, zone := range zones {
+		tags := n.withTags("server_zone:" + name)
+		agg.Add("gauge", metric.NewMetric("nginx.server_zone.processing", zone.Processing, tags))
+
+		fields := map[string]interface{}{
+			"requests":       zone.Requests,
+			"discarded":      zone.Discarded,
+			"bytes_received": zone.Received,
+			"bytes_sent":     zone.Sent,
+		}
+		addResponses(fields, zone.Responses.byClass())
+		agg.AddMetrics("rate", "nginx.server_zone", fields, tags, "")
+	}
+
+	var upstreams map[string]plusUpstream
+	if err := n.getJSON(base+"/http/upstreams", &upstreams); err != nil {
+		return err
+	}
+	for name, upstream := range upstreams {
+		for _, peer := range upstream.Peers {
+			tags := n.withTags("upstream:"+name, "upstream_peer:"+peer.Server)
+
+			up := 0
+			if peer.State == "up" {
+				up = 1
+			}
+			fields := map[string]interface{}{
+				"up":     up,
+				"active": peer.Active,
+			}
+			if peer.ResponseTime != nil {
+				fields["response_time"] = *peer.ResponseTime
+			}
+			if peer.HeaderTime != nil {
+				fields["header_time"] = *peer.HeaderTime
+			}
+			agg.AddMetrics("gauge", "nginx.upstream.peer", fields, tags, "")
+
+			fields = map[string]interface{}{
+				"requests":                peer.Requests,
+				"bytes_received":          peer.Received,
+				"bytes_sent":              peer.Sent,
+				"fails":                   peer.Fails,
+				"unavail":                 peer.Unavail,
+				"health_checks.checks":    peer.HealthChecks.Checks,
+				"health_checks.fails":     peer.HealthChecks.Fails,
+				"health_checks.unhealthy": peer.HealthChecks.Unhealthy,
+			}
+			addResponses(fields, peer.Responses.byClass())
+			agg.AddMetrics("rate", "nginx.upstream.peer", fields, tags, "")
+		}
+	}
+	return nil
+}
+
+func (n *Nginx) getJSON(requestURI string, v interface{}) error {
+	body, err := n.get(requestURI)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("Unable to decode the response of %s: %s", requestURI, err)
+	}
+	return nil
+}
+
+func (n *Nginx) withTags(tags ...string) []string {
+	result := make([]string, 0, len(n.Tags)+len(tags))
+	result = append(result, n.Tags...)
+	return append(result, tags...)
+}
+
+func addResponses(fields map[string]interface{}, responses map[string]int64) {
+	for _, class := range responseClasses {
+		fields["responses."+class] = responses[class]
+	}
+}
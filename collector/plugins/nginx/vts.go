@@ -0,0 +1,112 @@
+package nginx
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+// vtsStatus is the JSON status of nginx-module-vts, e.g.
+// http://localhost/status/format/json.
+type vtsStatus struct {
+	Connections struct {
+		Active   int64 `json:"active"`
+		Reading  int64 `json:"reading"`
+		Writing  int64 `json:"writing"`
+		Waiting  int64 `json:"waiting"`
+		Accepted int64 `json:"accepted"`
+		Handled  int64 `json:"handled"`
+		Requests int64 `json:"requests"`
+	} `json:"connections"`
+	ServerZones   map[string]vtsZone   `json:"serverZones"`
+	UpstreamZones map[string][]vtsPeer `json:"upstreamZones"`
+}
+
+type vtsZone struct {
+	RequestCounter int64            `json:"requestCounter"`
+	InBytes        int64            `json:"inBytes"`
+	OutBytes       int64            `json:"outBytes"`
+	Responses      map[string]int64 `json:"responses"`
+	RequestMsec    float64          `json:"requestMsec"`
+}
+
+type vtsPeer struct {
+	Server         string           `json:"server"`
+	RequestCounter int64            `json:"requestCounter"`
+	InBytes        int64            `json:"inBytes"`
+	OutBytes       int64            `json:"outBytes"`
+	Responses      map[string]int64 `json:"responses"`
+	RequestMsec    float64          `json:"requestMsec"`
+	ResponseMsec   float64          `json:"responseMsec"`
+	Down           bool             `json:"down"`
+}
+
+func (n *Nginx) collectVTSMetrics(body []byte, agg metric.Aggregator) error {
+	var status vtsStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("Unable to decode the VTS status: %s", err)
+	}
+	if status.ServerZones == nil {
+		return fmt.Errorf("Unknown JSON status, only the VTS module status is supported")
+	}
+
+	conns := status.Connections
+	fields := map[string]interface{}{
+		"connections": conns.Active,
+		"reading":     conns.Reading,
+		"writing":     conns.Writing,
+		"waiting":     conns.Waiting,
+	}
+	agg.AddMetrics("gauge", "nginx.net", fields, n.Tags, "")
+
+	fields = map[string]interface{}{
+		"conn_opened_per_s":  conns.Accepted,
+		"conn_dropped_per_s": conns.Accepted - conns.Handled,
+		"request_per_s":      conns.Requests,
+	}
+	agg.AddMetrics("rate", "nginx.net", fields, n.Tags, "")
+
+	for name, zone := range status.ServerZones {
+		// The zone * is the sum of the other zones.
+		if name == "*" {
+			continue
+		}
+		tags := n.withTags("server_zone:" + name)
+		agg.Add("gauge", metric.NewMetric("nginx.server_zone.request_time", zone.RequestMsec, tags))
+
+		fields := map[string]interface{}{
+			"requests":       zone.RequestCounter,
+			"bytes_received": zone.InBytes,
+			"bytes_sent":     zone.OutBytes,
+		}
+		addResponses(fields, zone.Responses)
+		agg.AddMetrics("rate", "nginx.server_zone", fields, tags, "")
+	}
+
+	for name, peers := range status.UpstreamZones {
+		for _, peer := range peers {
+			tags := n.withTags("upstream:"+name, "upstream_peer:"+peer.Server)
+
+			up := 1
+			if peer.Down {
+				up = 0
+			}
+			fields := map[string]interface{}{
+				"up":            up,
+				"request_time":  peer.RequestMsec,
+				"response_time": peer.ResponseMsec,
+			}
+			agg.AddMetrics("gauge", "nginx.upstream.peer", fields, tags, "")
+
+			fields = map[string]interface{}{
+				"requests":       peer.RequestCounter,
+				"bytes_received": peer.InBytes,
+				"bytes_sent":     peer.OutBytes,
+			}
+			addResponses(fields, peer.Responses)
+			agg.AddMetrics("rate", "nginx.upstream.peer", fields, tags, "")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package ntp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+)
+
+// NewNTP XXX
+func NewNTP(conf plugin.InitConfig) plugin.Plugin {
+	return &NTP{}
+}
+
+// NTP XXX
+type NTP struct {
+	Servers         []string
+	Timeout         int
+	OffsetThreshold float64 `yaml:"offset_threshold"`
+	Tags            []string
+}
+
+// Response holds the result of a query to a NTP server, the durations are
+// in seconds.
+type Response struct {
+	Offset  float64
+	Delay   float64
+	Stratum int
+}
+
+const (
+	defaultServer          = "pool.ntp.org"
+	defaultPort            = "123"
+	defaultTimeout         = 5
+	defaultOffsetThreshold = 60
+
+	// Seconds from the NTP epoch (1900) to the Unix epoch (1970)
+	ntpEpochOffset = 2208988800
+
+	packetSize = 48
+
+	// LI = 0 (no warning), VN = 4, Mode = 3 (client)
+	clientHeader = 0<<6 | 4<<3 | 3
+	modeServer   = 4
+	leapAlarm    = 3
+)
+
+// Check XXX
+func (n *NTP) Check(agg metric.Aggregator) error {
+	servers := n.Servers
+	if len(servers) == 0 {
+		servers = []string{defaultServer}
+	}
+
+	threshold := n.OffsetThreshold
+	if threshold <= 0 {
+		threshold = defaultOffsetThreshold
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		tags := make([]string, 0, len(n.Tags)+1)
+		tags = append(tags, n.Tags...)
+		tags = append(tags, "ntp_server:"+server)
+
+		rsp, err := n.query(server)
+		if err != nil {
+			log.Errorf("Failed to query NTP server %s. %s", server, err)
+			agg.AddServiceCheck(metric.NewServiceCheck("ntp.in_sync", metric.StatusUnknown, err.Error(), tags))
+			lastErr = err
+			continue
+		}
+
+		agg.Add("gauge", metric.NewMetric("ntp.offset", rsp.Offset, tags))
+		agg.Add("gauge", metric.NewMetric("ntp.delay", rsp.Delay, tags))
+		agg.Add("gauge", metric.NewMetric("ntp.stratum", rsp.Stratum, tags))
+
+		if math.Abs(rsp.Offset) > threshold {
+			msg := fmt.Sprintf("Offset %.3fs is higher than the threshold %.0fs", rsp.Offset, threshold)
+			agg.AddServiceCheck(metric.NewServiceCheck("ntp.in_sync", metric.StatusCritical, msg, tags))
+		} else {
+			agg.AddServiceCheck(metric.NewServiceCheck("ntp.in_sync", metric.StatusOK, "", tags))
+		}
+	}
+	return lastErr
+}
+
+// query sends a SNTP v4 request (RFC 4330) to the server.
+func (n *NTP) query(server string) (*Response, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, defaultPort)
+	}
+
+	timeout := time.Duration(n.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout * time.Second
+	}
+
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	req := make([]byte, packetSize)
+	req[0] = clientHeader
+	// The server copies the transmit timestamp into the originate timestamp
+	// of the response, which is used to match the response.
+	t1 := time.Now()
+	origin := toNTPTime(t1)
+	binary.BigEndian.PutUint64(req[40:], origin)
+
+	_, err = conn.Write(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := make([]byte, packetSize)
+	size, err := conn.Read(rsp)
+	if err != nil {
+		return nil, err
+	}
+	t4 := time.Now()
+
+	return parseResponse(rsp[:size], origin, t1, t4)
+}
+
+func parseResponse(rsp []byte, origin uint64, t1, t4 time.Time) (*Response, error) {
+	if len(rsp) < packetSize {
+		return nil, fmt.Errorf("invalid NTP response of %d bytes", len(rsp))
+	}
+
+	leap := rsp[0] >> 6
+	mode := rsp[0] & 0x7
+	stratum := int(rsp[1])
+	if mode != modeServer {
+		return nil, fmt.Errorf("invalid mode %d of NTP response", mode)
+	}
+	if binary.BigEndian.Uint64(rsp[24:]) != origin {
+		return nil, fmt.Errorf("NTP response doesn't match the request")
+	}
+	if stratum == 0 {
+		// Kiss-o'-Death, e.g. RATE when the server asks to slow down.
+		return nil, fmt.Errorf("NTP server sent Kiss-o'-Death %q", string(rsp[12:16]))
+	}
+	if leap == leapAlarm {
+		return nil, fmt.Errorf("NTP server is not synchronized")
+	}
+
+	t2 := fromNTPTime(binary.BigEndian.Uint64(rsp[32:]))
+	t3 := fromNTPTime(binary.BigEndian.Uint64(rsp[40:]))
+
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delay := t4.Sub(t1) - t3.Sub(t2)
+	return &Response{
+		Offset:  offset.Seconds(),
+		Delay:   delay.Seconds(),
+		Stratum: stratum,
+	}, nil
+}
+
+// toNTPTime converts the time into the 64-bit NTP timestamp, the seconds since
+// 1900 in the high 32 bits and the fraction of second in the low 32 bits.
+func toNTPTime(t time.Time) uint64 {
+	nsec := uint64(t.UnixNano()) + ntpEpochOffset*1e9
+	sec := nsec / 1e9
+	frac := (nsec % 1e9) << 32 / 1e9
+	return sec<<32 | frac
+}
+
+func fromNTPTime(ts uint64) time.Time {
+	sec := int64(ts>>32) - ntpEpochOffset
+	nsec := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(sec, nsec)
+}
+
+func init() {
+	collector.Add("ntp", NewNTP)
+}
@@ -0,0 +1,175 @@
+package ntp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+// serve starts a fake NTP server whose clock is ahead by skew.
+func serve(t *testing.T, skew time.Duration, stratum byte) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		req := make([]byte, packetSize)
+		for {
+			_, addr, err := conn.ReadFrom(req)
+			if err != nil {
+				return
+			}
+			now := toNTPTime(time.Now().Add(skew))
+
+			rsp := make([]byte, packetSize)
+			rsp[0] = 0<<6 | 4<<3 | modeServer
+			rsp[1] = stratum
+			if stratum == 0 {
+				copy(rsp[12:], "RATE")
+			}
+			copy(rsp[24:32], req[40:48])
+			binary.BigEndian.PutUint64(rsp[32:], now)
+			binary.BigEndian.PutUint64(rsp[40:], now)
+			conn.WriteTo(rsp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func collect(t *testing.T, n *NTP) ([]metric.Metric, []metric.ServiceCheck, error) {
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	err := n.Check(agg)
+	agg.Flush()
+
+	var metrics []metric.Metric
+	var serviceChecks []metric.ServiceCheck
+	for len(metricC) > 0 {
+		m := <-metricC
+		if sc, ok := m.Value.(metric.ServiceCheck); ok {
+			serviceChecks = append(serviceChecks, sc)
+		} else {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, serviceChecks, err
+}
+
+func TestNTPCheck(t *testing.T) {
+	server, stop := serve(t, 0, 2)
+	defer stop()
+
+	n := &NTP{
+		Servers: []string{server},
+		Tags:    []string{"env:test"},
+	}
+	metrics, serviceChecks, err := collect(t, n)
+	require.NoError(t, err)
+
+	tags := []string{"env:test", "ntp_server:" + server}
+	require.Len(t, metrics, 3)
+	testutil.AssertContainsMetricWithTags(t, metrics, "ntp.offset", 0, tags, 0.1)
+	testutil.AssertContainsMetricWithTags(t, metrics, "ntp.delay", 0, tags, 0.1)
+	testutil.AssertContainsMetricWithTags(t, metrics, "ntp.stratum", 2, tags)
+
+	require.Len(t, serviceChecks, 1)
+	assert.Equal(t, "ntp.in_sync", serviceChecks[0].Check)
+	assert.Equal(t, metric.StatusOK, serviceChecks[0].Status)
+	assert.Equal(t, tags, serviceChecks[0].Tags)
+}
+
+func TestNTPCheckOffsetExceeded(t *testing.T) {
+	server, stop := serve(t, -120*time.Second, 2)
+	defer stop()
+
+	n := &NTP{
+		Servers:         []string{server},
+		OffsetThreshold: 30,
+	}
+	metrics, serviceChecks, err := collect(t, n)
+	require.NoError(t, err)
+
+	tags := []string{"ntp_server:" + server}
+	testutil.AssertContainsMetricWithTags(t, metrics, "ntp.offset", -120, tags, 0.1)
+
+	require.Len(t, serviceChecks, 1)
+	assert.Equal(t, metric.StatusCritical, serviceChecks[0].Status)
+	assert.Contains(t, serviceChecks[0].Message, "higher than the threshold 30s")
+}
+
+func TestNTPCheckKissOfDeath(t *testing.T) {
+	server, stop := serve(t, 0, 0)
+	defer stop()
+
+	n := &NTP{
+		Servers: []string{server},
+	}
+	metrics, serviceChecks, err := collect(t, n)
+	assert.EqualError(t, err, `NTP server sent Kiss-o'-Death "RATE"`)
+	assert.Empty(t, metrics)
+
+	require.Len(t, serviceChecks, 1)
+	assert.Equal(t, metric.StatusUnknown, serviceChecks[0].Status)
+}
+
+func TestNTPCheckTimeout(t *testing.T) {
+	// Nobody answers on the port.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	n := &NTP{
+		Servers: []string{conn.LocalAddr().String()},
+		Timeout: 1,
+	}
+	_, serviceChecks, err := collect(t, n)
+	assert.Error(t, err)
+	require.Len(t, serviceChecks, 1)
+	assert.Equal(t, metric.StatusUnknown, serviceChecks[0].Status)
+}
+
+func TestParseResponse(t *testing.T) {
+	t1 := time.Unix(1500000000, 0)
+	t4 := t1.Add(100 * time.Millisecond)
+	origin := toNTPTime(t1)
+
+	rsp := make([]byte, packetSize)
+	rsp[0] = 0<<6 | 4<<3 | modeServer
+	rsp[1] = 1
+	binary.BigEndian.PutUint64(rsp[24:], origin)
+	// The server received the request 10s ahead of the client, and replied
+	// 20ms later.
+	binary.BigEndian.PutUint64(rsp[32:], toNTPTime(t1.Add(10*time.Second+40*time.Millisecond)))
+	binary.BigEndian.PutUint64(rsp[40:], toNTPTime(t1.Add(10*time.Second+60*time.Millisecond)))
+
+	r, err := parseResponse(rsp, origin, t1, t4)
+	require.NoError(t, err)
+	assert.InDelta(t, 10, r.Offset, 1e-6)
+	assert.InDelta(t, 0.08, r.Delay, 1e-6)
+	assert.Equal(t, 1, r.Stratum)
+
+	_, err = parseResponse(rsp, origin+1, t1, t4)
+	assert.EqualError(t, err, "NTP response doesn't match the request")
+
+	rsp[0] = leapAlarm<<6 | 4<<3 | modeServer
+	_, err = parseResponse(rsp, origin, t1, t4)
+	assert.EqualError(t, err, "NTP server is not synchronized")
+
+	_, err = parseResponse(rsp[:10], origin, t1, t4)
+	assert.Error(t, err)
+}
+
+func TestNTPTime(t *testing.T) {
+	now := time.Unix(1500000000, 123456789)
+	assert.InDelta(t, now.UnixNano(), fromNTPTime(toNTPTime(now)).UnixNano(), 1)
+	assert.Equal(t, uint64(ntpEpochOffset)<<32, toNTPTime(time.Unix(0, 0)))
+}
@@ -0,0 +1,204 @@
+package phpfpm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal FastCGI client (https://fast-cgi.github.io/spec) to query the
+// status page of a pool without a web server in front of it.
+
+const (
+	fcgiVersion = 1
+
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+
+	fcgiResponder = 1
+
+	fcgiRequestID = 1
+	fcgiMaxWrite  = 65535
+)
+
+type fcgiHeader struct {
+	Version       uint8
+	Type          uint8
+	RequestID     uint16
+	ContentLength uint16
+	PaddingLength uint8
+	Reserved      uint8
+}
+
+// fcgiGet sends a GET request of the path to the FastCGI server, and returns
+// the body of the response.
+func fcgiGet(network, address, path, query string, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	requestURI := path
+	if query != "" {
+		requestURI += "?" + query
+	}
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"REQUEST_METHOD":    "GET",
+		"SCRIPT_NAME":       path,
+		"SCRIPT_FILENAME":   path,
+		"QUERY_STRING":      query,
+		"REQUEST_URI":       requestURI,
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"CONTENT_LENGTH":    "0",
+		"REMOTE_ADDR":       "127.0.0.1",
+	}
+
+	w := bufio.NewWriter(conn)
+	// role, flags and 5 reserved bytes
+	begin := []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0}
+	if err = writeRecord(w, fcgiBeginRequest, begin); err != nil {
+		return nil, err
+	}
+	if err = writeRecord(w, fcgiParams, encodeParams(params)); err != nil {
+		return nil, err
+	}
+	// The empty records end the streams of params and stdin.
+	if err = writeRecord(w, fcgiParams, nil); err != nil {
+		return nil, err
+	}
+	if err = writeRecord(w, fcgiStdin, nil); err != nil {
+		return nil, err
+	}
+	if err = w.Flush(); err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, err := readResponse(bufio.NewReader(conn))
+	if err != nil {
+		return nil, err
+	}
+	return parseCGIResponse(stdout, stderr)
+}
+
+func writeRecord(w io.Writer, recType uint8, content []byte) error {
+	for {
+		chunk := content
+		if len(chunk) > fcgiMaxWrite {
+			chunk = content[:fcgiMaxWrite]
+		}
+		padding := uint8(-len(chunk) & 7)
+		h := fcgiHeader{
+			Version:       fcgiVersion,
+			Type:          recType,
+			RequestID:     fcgiRequestID,
+			ContentLength: uint16(len(chunk)),
+			PaddingLength: padding,
+		}
+		if err := binary.Write(w, binary.BigEndian, h); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		if _, err := w.Write(make([]byte, padding)); err != nil {
+			return err
+		}
+
+		content = content[len(chunk):]
+		if len(content) == 0 {
+			return nil
+		}
+	}
+}
+
+// encodeParams encodes the name-value pairs, whose lengths are encoded in 1
+// byte below 128, or in 4 bytes with the high bit set.
+func encodeParams(params map[string]string) []byte {
+	var buf bytes.Buffer
+	writeLen := func(n int) {
+		if n < 128 {
+			buf.WriteByte(byte(n))
+			return
+		}
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(n)|1<<31)
+		buf.Write(b)
+	}
+	for name, value := range params {
+		writeLen(len(name))
+		writeLen(len(value))
+		buf.WriteString(name)
+		buf.WriteString(value)
+	}
+	return buf.Bytes()
+}
+
+// readResponse reads the records until the end of the request.
+func readResponse(r io.Reader) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	for {
+		var h fcgiHeader
+		if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+			return nil, nil, fmt.Errorf("Unable to read FastCGI record: %s", err)
+		}
+		content := make([]byte, int(h.ContentLength)+int(h.PaddingLength))
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, nil, fmt.Errorf("Unable to read FastCGI record: %s", err)
+		}
+		content = content[:h.ContentLength]
+
+		switch h.Type {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			stderr.Write(content)
+		case fcgiEndRequest:
+			return stdout.Bytes(), stderr.Bytes(), nil
+		}
+	}
+}
+
+// parseCGIResponse parses the headers of the response, which contain the
+// HTTP status in a Status header if it isn't 200 OK.
+func parseCGIResponse(stdout, stderr []byte) ([]byte, error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(stdout)))
+	header, err := r.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("Unable to parse FastCGI response headers: %s", err)
+	}
+
+	if status := header.Get("Status"); status != "" {
+		code, err := strconv.Atoi(strings.SplitN(status, " ", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid FastCGI response status '%s'", status)
+		}
+		if code != http.StatusOK {
+			msg := strings.TrimSpace(string(stderr))
+			if msg == "" {
+				msg = status
+			}
+			return nil, fmt.Errorf("FastCGI request failed with status %d: %s", code, msg)
+		}
+	}
+	return ioutil.ReadAll(r.R)
+}
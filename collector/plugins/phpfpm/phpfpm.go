@@ -2,6 +2,7 @@ package phpfpm
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -21,10 +22,23 @@ func NewPHPFPM(conf plugin.InitConfig) plugin.Plugin {
 
 // PHPFPM XXX
 type PHPFPM struct {
-	StatusURL string `yaml:"status_url"`
-	User      string
-	Password  string
-	Tags      []string
+	StatusURL  string `yaml:"status_url"`
+	StatusPath string `yaml:"status_path"`
+	User       string
+	Password   string
+	Tags       []string
+}
+
+// fpmStatus is the full status of a pool in JSON.
+type fpmStatus struct {
+	Pool      string       `json:"pool"`
+	Processes []fpmProcess `json:"processes"`
+}
+
+type fpmProcess struct {
+	State           string  `json:"state"`
+	RequestURI      string  `json:"request uri"`
+	RequestDuration float64 `json:"request duration"` // in microseconds
 }
 
 var (
@@ -46,6 +60,14 @@ var (
 	}
 )
 
+const (
+	defaultStatusPath = "/status"
+	fcgiTimeout       = 4 * time.Second
+)
+
+// A pool without running or idle processes still reports their counts.
+var processStates = []string{"idle", "running"}
+
 var tr = &http.Transport{
 	ResponseHeaderTimeout: time.Duration(3 * time.Second),
 }
@@ -57,6 +79,10 @@ var client = &http.Client{
 
 // Check XXX
 func (pf *PHPFPM) Check(agg metric.Aggregator) error {
+	if strings.HasPrefix(pf.StatusURL, "fcgi://") || strings.HasPrefix(pf.StatusURL, "unix://") {
+		return pf.checkFastCGI(agg)
+	}
+
 	addr, err := url.Parse(pf.StatusURL)
 	if err != nil {
 		return fmt.Errorf("Unable to parse address '%s': %s", pf.StatusURL, err)
@@ -83,7 +109,7 @@ func (pf *PHPFPM) Check(agg metric.Aggregator) error {
 	sc := bufio.NewScanner(resp.Body)
 	gaugeFields := make(map[string]interface{})
 	mcFields := make(map[string]interface{})
-	var tags []string
+	var pool string
 	for sc.Scan() {
 		line := sc.Text()
 		if strings.Contains(line, ":") {
@@ -95,7 +121,7 @@ func (pf *PHPFPM) Check(agg metric.Aggregator) error {
 
 			switch key {
 			case "pool":
-				tags = append(tags, "pool:"+strings.Trim(part, " "))
+				pool = strings.Trim(part, " ")
 			default:
 				value, err := strconv.ParseFloat(part, 64)
 				if err != nil {
@@ -114,10 +140,7 @@ func (pf *PHPFPM) Check(agg metric.Aggregator) error {
 			}
 		}
 	}
-	if tags == nil {
-		tags = append(tags, "pool:default")
-	}
-	tags = append(tags, pf.Tags...)
+	tags := pf.poolTags(pool)
 
 	if len(gaugeFields) > 0 {
 		agg.AddMetrics("gauge", "php_fpm", gaugeFields, tags, "")
@@ -130,6 +153,104 @@ func (pf *PHPFPM) Check(agg metric.Aggregator) error {
 	return nil
 }
 
+// checkFastCGI gets the full status of the pool over FastCGI, from a TCP
+// address like fcgi://127.0.0.1:9000/status or a Unix socket like
+// unix:///var/run/php-fpm.sock.
+func (pf *PHPFPM) checkFastCGI(agg metric.Aggregator) error {
+	addr, err := url.Parse(pf.StatusURL)
+	if err != nil {
+		return fmt.Errorf("Unable to parse address '%s': %s", pf.StatusURL, err)
+	}
+
+	network, address, path := "tcp", addr.Host, addr.Path
+	if addr.Scheme == "unix" {
+		network, address, path = "unix", addr.Path, ""
+	}
+	if path == "" || path == "/" {
+		path = pf.StatusPath
+	}
+	if path == "" {
+		path = defaultStatusPath
+	}
+
+	body, err := fcgiGet(network, address, path, "json&full", fcgiTimeout)
+	if err != nil {
+		return fmt.Errorf("Unable to get phpfpm status from %s: %s", pf.StatusURL, err)
+	}
+	return pf.collectStatus(agg, body, path)
+}
+
+func (pf *PHPFPM) collectStatus(agg metric.Aggregator, body []byte, statusPath string) error {
+	var status fpmStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("Unable to decode phpfpm status: %s", err)
+	}
+	// The pool metrics have the same names as the plain text status.
+	var values map[string]interface{}
+	if err := json.Unmarshal(body, &values); err != nil {
+		return fmt.Errorf("Unable to decode phpfpm status: %s", err)
+	}
+
+	tags := pf.poolTags(status.Pool)
+
+	gaugeFields := make(map[string]interface{})
+	mcFields := make(map[string]interface{})
+	for key, v := range values {
+		value, ok := v.(float64)
+		if !ok {
+			continue
+		}
+		if name, ok := GAUGES[key]; ok {
+			gaugeFields[name] = value
+		}
+		if name, ok := MONOTONICCOUNTS[key]; ok {
+			mcFields[name] = value
+		}
+	}
+	if len(gaugeFields) > 0 {
+		agg.AddMetrics("gauge", "php_fpm", gaugeFields, tags, "")
+	}
+	if len(mcFields) > 0 {
+		agg.AddMetrics("monotoniccount", "php_fpm", mcFields, tags, "")
+	}
+
+	states := make(map[string]int)
+	for _, state := range processStates {
+		states[state] = 0
+	}
+	for _, p := range status.Processes {
+		state := strings.Replace(strings.ToLower(p.State), " ", "_", -1)
+		states[state]++
+		// An idle process reports its last request until it serves another
+		// one, and the running process serving this status request is not
+		// one of the requests of the pool.
+		if state == "idle" || isStatusRequest(p.RequestURI, statusPath) {
+			continue
+		}
+		agg.Add("histogram", metric.NewMetric("php_fpm.requests.duration", p.RequestDuration/1e6, tags))
+	}
+	for state, count := range states {
+		stateTags := make([]string, 0, len(tags)+1)
+		stateTags = append(stateTags, tags...)
+		stateTags = append(stateTags, "state:"+state)
+		agg.Add("gauge", metric.NewMetric("php_fpm.processes.count", count, stateTags))
+	}
+	return nil
+}
+
+func (pf *PHPFPM) poolTags(pool string) []string {
+	if pool == "" {
+		pool = "default"
+	}
+	tags := make([]string, 0, len(pf.Tags)+1)
+	tags = append(tags, "pool:"+pool)
+	return append(tags, pf.Tags...)
+}
+
+func isStatusRequest(uri, statusPath string) bool {
+	return uri == statusPath || strings.HasPrefix(uri, statusPath+"?")
+}
+
 func init() {
 	collector.Add("phpfpm", NewPHPFPM)
 }
@@ -2,11 +2,19 @@ package phpfpm
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/fcgi"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var pfStatus = `
@@ -93,3 +101,86 @@ func TestPHPFPMCheck(t *testing.T) {
 	}
 	testutil.AssertCheckWithRateMetrics(t, pf.Check, pf2.Check, 9, fields, tags)
 }
+
+var pfFullStatus = `{"pool":"www","process manager":"dynamic","start time":1479262207,"start since":28225,"accepted conn":3,"listen queue":0,"max listen queue":0,"listen queue len":0,"idle processes":2,"active processes":2,"total processes":4,"max active processes":2,"max children reached":0,"slow requests":0,"processes":[{"pid":22,"state":"Idle","start time":1479262207,"start since":28225,"requests":1,"request duration":2000,"request method":"GET","request uri":"/index.php","content length":0,"user":"-","script":"/var/www/index.php","last request cpu":0.00,"last request memory":2097152},{"pid":23,"state":"Idle","start time":1479262207,"start since":28225,"requests":1,"request duration":4000,"request method":"GET","request uri":"/index.php","content length":0,"user":"-","script":"/var/www/index.php","last request cpu":0.00,"last request memory":2097152},{"pid":24,"state":"Running","start time":1479262207,"start since":28225,"requests":1,"request duration":6000,"request method":"GET","request uri":"/status?json&full","content length":0,"user":"-","script":"-","last request cpu":0.00,"last request memory":0},{"pid":25,"state":"Running","start time":1479262207,"start since":28225,"requests":2,"request duration":8000,"request method":"GET","request uri":"/slow.php","content length":0,"user":"-","script":"/var/www/slow.php","last request cpu":0.00,"last request memory":0}]}`
+
+func serveFastCGI(l net.Listener) {
+	go fcgi.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" || r.URL.RawQuery != "json&full" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, pfFullStatus)
+	}))
+}
+
+func TestPHPFPMFastCGICheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	serveFastCGI(l)
+
+	pf := PHPFPM{
+		StatusURL: fmt.Sprintf("fcgi://%s/status", l.Addr().String()),
+		Tags:      []string{"service:phpfpm"},
+	}
+
+	fields := map[string]float64{
+		"php_fpm.listen_queue.size":     0,
+		"php_fpm.listen_queue.max_size": 0,
+		"php_fpm.processes.idle":        2,
+		"php_fpm.processes.active":      2,
+		"php_fpm.processes.total":       4,
+		"php_fpm.processes.max_active":  2,
+		// Neither the idle processes nor the status request are sampled.
+		"php_fpm.requests.duration.max": 0.008,
+		"php_fpm.requests.duration.avg": 0.008,
+	}
+	tags := []string{
+		"pool:www",
+		"service:phpfpm",
+	}
+	testutil.AssertCheckWithMetrics(t, pf.Check, 13, fields, tags, 0.0001)
+
+	fields = map[string]float64{
+		"php_fpm.processes.count": 2,
+	}
+	tags = []string{
+		"pool:www",
+		"service:phpfpm",
+		"state:idle",
+	}
+	testutil.AssertCheckWithMetrics(t, pf.Check, 13, fields, tags)
+
+	// The status path is not the default one.
+	pf.StatusURL = fmt.Sprintf("fcgi://%s/fpm-status", l.Addr().String())
+	err = pf.Check(testutil.MockAggregator(make(chan metric.Metric, 100)))
+	assert.Error(t, err)
+}
+
+func TestPHPFPMUnixSocketCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phpfpm")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "php-fpm.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+	serveFastCGI(l)
+
+	pf := PHPFPM{
+		StatusURL: "unix://" + path,
+		Tags:      []string{"service:phpfpm"},
+	}
+
+	fields := map[string]float64{
+		"php_fpm.processes.count": 2,
+	}
+	tags := []string{
+		"pool:www",
+		"service:phpfpm",
+		"state:running",
+	}
+	testutil.AssertCheckWithMetrics(t, pf.Check, 13, fields, tags)
+}
@@ -3,14 +3,23 @@ package plugins
 import (
 	// registry all plugins
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/apache"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/cgroup"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/consul"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/directory"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/docker"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/haproxy"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/kubelet"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/linuxkernel"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/logparser"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/memcached"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/mongodb"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/mysql"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/nginx"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/ntp"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/phpfpm"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/postgres"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/redis"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/sensors"
 	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/system"
+	_ "github.com/cloudinsight/cloudinsight-agent/collector/plugins/zookeeper"
 )
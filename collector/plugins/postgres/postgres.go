@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/cloudinsight/cloudinsight-agent/collector"
 	"github.com/cloudinsight/cloudinsight-agent/common/log"
@@ -22,10 +24,11 @@ func NewPostgres(conf plugin.InitConfig) plugin.Plugin {
 
 // Postgres XXX
 type Postgres struct {
-	Address       string
-	Tags          []string
-	Relations     []relationConfig
-	CustomMetrics []metricSchema `yaml:"custom_metrics"`
+	Address         string
+	Tags            []string
+	Relations       []relationConfig
+	CustomMetrics   []metricSchema `yaml:"custom_metrics"`
+	StatementsLimit int            `yaml:"statements_limit"`
 
 	version          string
 	formattedAddress string
@@ -39,6 +42,20 @@ const (
 
 	versionQuery     = `SHOW SERVER_VERSION`
 	maxCustomResults = 100
+
+	statementsExtensionQuery = `SELECT count(*) FROM pg_extension WHERE extname = 'pg_stat_statements'`
+	// The columns of time are renamed in 13, and queryid is added in 9.4.
+	statementsQuery = `
+SELECT d.datname, r.rolname, %s, s.query, s.calls, s.%s, s.rows,
+       s.shared_blks_hit, s.shared_blks_read, s.temp_blks_read, s.temp_blks_written
+  FROM pg_stat_statements s
+  JOIN pg_database d ON (s.dbid = d.oid)
+  JOIN pg_roles r ON (s.userid = r.oid)
+ ORDER BY s.%[2]s DESC
+ LIMIT $1
+`
+	defaultStatementsLimit = 50
+	maxQueryTextLength     = 200
 )
 
 type tagField struct {
@@ -79,16 +96,16 @@ SELECT datname,
 	}
 
 	commonMetrics = map[string]metric.Field{
-		"numbackends":                                   {"postgresql.connections", gauge},
-		"xact_commit":                                   {"postgresql.commits", rate},
-		"xact_rollback":                                 {"postgresql.rollbacks", rate},
-		"blks_read":                                     {"postgresql.disk_read", rate},
-		"blks_hit":                                      {"postgresql.buffer_hit", rate},
-		"tup_returned":                                  {"postgresql.rows_returned", rate},
-		"tup_fetched":                                   {"postgresql.rows_fetched", rate},
-		"tup_inserted":                                  {"postgresql.rows_inserted", rate},
-		"tup_updated":                                   {"postgresql.rows_updated", rate},
-		"tup_deleted":                                   {"postgresql.rows_deleted", rate},
+		"numbackends":   {"postgresql.connections", gauge},
+		"xact_commit":   {"postgresql.commits", rate},
+		"xact_rollback": {"postgresql.rollbacks", rate},
+		"blks_read":     {"postgresql.disk_read", rate},
+		"blks_hit":      {"postgresql.buffer_hit", rate},
+		"tup_returned":  {"postgresql.rows_returned", rate},
+		"tup_fetched":   {"postgresql.rows_fetched", rate},
+		"tup_inserted":  {"postgresql.rows_inserted", rate},
+		"tup_updated":   {"postgresql.rows_updated", rate},
+		"tup_deleted":   {"postgresql.rows_deleted", rate},
 		"pg_database_size(datname) AS pg_database_size": {"postgresql.database_size", gauge},
 	}
 
@@ -173,6 +190,10 @@ SELECT mode,
 			"n_tup_hot_upd": {"postgresql.rows_hot_updated", rate},
 			"n_live_tup":    {"postgresql.live_rows", gauge},
 			"n_dead_tup":    {"postgresql.dead_rows", gauge},
+			"EXTRACT(EPOCH FROM now() - last_vacuum)::float AS last_vacuum_age":           {"postgresql.last_vacuum_age", gauge},
+			"EXTRACT(EPOCH FROM now() - last_autovacuum)::float AS last_autovacuum_age":   {"postgresql.last_autovacuum_age", gauge},
+			"EXTRACT(EPOCH FROM now() - last_analyze)::float AS last_analyze_age":         {"postgresql.last_analyze_age", gauge},
+			"EXTRACT(EPOCH FROM now() - last_autoanalyze)::float AS last_autoanalyze_age": {"postgresql.last_autoanalyze_age", gauge},
 		},
 		Query: `
 SELECT relname, schemaname, %s
@@ -256,6 +277,102 @@ SELECT schemaname, count(*) AS %s
 `,
 	}
 
+	activityMetrics = metricSchema{
+		Descriptors: []tagField{
+			{
+				Name:  "datname",
+				Alias: "db",
+			},
+		},
+		Metrics: map[string]metric.Field{
+			"COUNT(xact_start) AS xact_open": {"postgresql.transactions.open", gauge},
+			"COALESCE(MAX(EXTRACT(EPOCH FROM now() - xact_start)), 0)::float AS xact_max_age": {
+				"postgresql.transactions.max_age", gauge,
+			},
+			"SUM(CASE WHEN state LIKE 'idle in transaction%' THEN 1 ELSE 0 END) AS idle_in_xact": {
+				"postgresql.transactions.idle_in_transaction", gauge,
+			},
+			"COALESCE(MAX(CASE WHEN state LIKE 'idle in transaction%' THEN EXTRACT(EPOCH FROM now() - state_change) END), 0)::float AS idle_in_xact_max_age": {
+				"postgresql.transactions.idle_in_transaction_max_age", gauge,
+			},
+		},
+		Relation: false,
+		Query: `
+SELECT datname, %s
+  FROM pg_stat_activity
+ WHERE datname IS NOT NULL
+   AND pid <> pg_backend_pid()
+ GROUP BY datname
+`,
+	}
+
+	// The IDs wrap around after 2^31 transactions, autovacuum must freeze the
+	// old rows before that.
+	wraparoundMetrics = metricSchema{
+		Descriptors: []tagField{
+			{
+				Name:  "datname",
+				Alias: "db",
+			},
+		},
+		Metrics: map[string]metric.Field{
+			"age(datfrozenxid) AS xid_age": {"postgresql.xid_age", gauge},
+			"age(datfrozenxid)::float / 2147483648 * 100 AS pct_towards_wraparound": {
+				"postgresql.percent_towards_wraparound", gauge,
+			},
+		},
+		Relation: false,
+		Query: `
+SELECT datname, %s
+  FROM pg_database
+ WHERE datallowconn
+`,
+	}
+
+	commonReplSlotMetrics = map[string]metric.Field{
+		"active::int AS active": {"postgresql.replication_slot.active", gauge},
+	}
+
+	replicationSlotMetrics94 = map[string]metric.Field{
+		`pg_xlog_location_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_xlog_receive_location()
+		 ELSE pg_current_xlog_location() END, restart_lsn)::bigint AS retained_bytes`: {"postgresql.replication_slot.retained_bytes", gauge},
+	}
+
+	replicationSlotMetrics10 = map[string]metric.Field{
+		`pg_wal_lsn_diff(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_receive_lsn()
+		 ELSE pg_current_wal_lsn() END, restart_lsn)::bigint AS retained_bytes`: {"postgresql.replication_slot.retained_bytes", gauge},
+	}
+
+	replicationSlotMetrics = metricSchema{
+		Descriptors: []tagField{
+			{
+				Name:  "slot_name",
+				Alias: "slot_name",
+			},
+			{
+				Name:  "slot_type",
+				Alias: "slot_type",
+			},
+		},
+		Metrics:  map[string]metric.Field{},
+		Relation: false,
+		Query: `
+SELECT slot_name, slot_type, %s
+  FROM pg_replication_slots
+`,
+	}
+
+	statementMetrics = map[string]metric.Field{
+		"calls":             {"postgresql.queries.count", rate},
+		"total_time":        {"postgresql.queries.time", rate},
+		"mean_time":         {"postgresql.queries.mean_time", gauge},
+		"rows":              {"postgresql.queries.rows", rate},
+		"shared_blks_hit":   {"postgresql.queries.shared_blks_hit", rate},
+		"shared_blks_read":  {"postgresql.queries.shared_blks_read", rate},
+		"temp_blks_read":    {"postgresql.queries.temp_blks_read", rate},
+		"temp_blks_written": {"postgresql.queries.temp_blks_written", rate},
+	}
+
 	replicationMetrics91 = map[string]metric.Field{
 		`CASE WHEN pg_last_xlog_receive_location() = pg_last_xlog_replay_location()
 		 THEN 0 ELSE GREATEST(0, EXTRACT (EPOCH FROM now() - pg_last_xact_replay_timestamp()))
@@ -381,6 +498,18 @@ func (p *Postgres) collectMetrics(db *sql.DB, agg metric.Aggregator) error {
 	fullMetricSchemas = append(fullMetricSchemas, connectionMetrics)
 	fullMetricSchemas = append(fullMetricSchemas, lockMetrics)
 	fullMetricSchemas = append(fullMetricSchemas, countMetrics)
+	fullMetricSchemas = append(fullMetricSchemas, wraparoundMetrics)
+
+	if p.versionAtLeast("9.2.0") {
+		fullMetricSchemas = append(fullMetricSchemas, activityMetrics)
+	}
+
+	if p.versionAtLeast("9.4.0") {
+		if len(replicationSlotMetrics.Metrics) == 0 {
+			p.updateReplSlotMetrics()
+		}
+		fullMetricSchemas = append(fullMetricSchemas, replicationSlotMetrics)
+	}
 
 	if p.Relations != nil {
 		fullMetricSchemas = append(fullMetricSchemas, relMetrics)
@@ -403,6 +532,13 @@ func (p *Postgres) collectMetrics(db *sql.DB, agg metric.Aggregator) error {
 		}
 	}
 
+	if p.versionAtLeast("9.2.0") {
+		err = p.collectStatementMetrics(db, agg)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -426,30 +562,69 @@ func (p *Postgres) getVersion(db *sql.DB) (string, error) {
 
 func (p *Postgres) updateDBMetrics() {
 	metric.UpdateMap(dbMetrics.Metrics, commonMetrics)
-	if p.version >= "9.2.0" {
+	if p.versionAtLeast("9.2.0") {
 		metric.UpdateMap(dbMetrics.Metrics, newer92Metrics)
 	}
 }
 
 func (p *Postgres) updateBGWMetrics() {
 	metric.UpdateMap(bgwMetrics.Metrics, commonBGWMetrics)
-	if p.version >= "9.1.0" {
+	if p.versionAtLeast("9.1.0") {
 		metric.UpdateMap(bgwMetrics.Metrics, newer91BGWMetrics)
 	}
-	if p.version >= "9.2.0" {
+	if p.versionAtLeast("9.2.0") {
 		metric.UpdateMap(bgwMetrics.Metrics, newer92BGWMetrics)
 	}
 }
 
 func (p *Postgres) updateReplMetrics() {
-	if p.version >= "9.1.0" {
+	if p.versionAtLeast("9.1.0") {
 		metric.UpdateMap(replicationMetrics.Metrics, replicationMetrics91)
 	}
-	if p.version >= "9.2.0" {
+	if p.versionAtLeast("9.2.0") {
 		metric.UpdateMap(replicationMetrics.Metrics, replicationMetrics92)
 	}
 }
 
+// The functions of WAL have been renamed from xlog in 10.
+func (p *Postgres) updateReplSlotMetrics() {
+	metric.UpdateMap(replicationSlotMetrics.Metrics, commonReplSlotMetrics)
+	if p.versionAtLeast("10.0.0") {
+		metric.UpdateMap(replicationSlotMetrics.Metrics, replicationSlotMetrics10)
+	} else {
+		metric.UpdateMap(replicationSlotMetrics.Metrics, replicationSlotMetrics94)
+	}
+}
+
+// versionAtLeast compares the server version numerically, since "10.1" is
+// lower than "9.2.0" as a string.
+func (p *Postgres) versionAtLeast(version string) bool {
+	current := parseVersion(p.version)
+	required := parseVersion(version)
+	for i := range current {
+		if current[i] != required[i] {
+			return current[i] > required[i]
+		}
+	}
+	return true
+}
+
+var versionMatcher = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// parseVersion parses the leading numbers of versions like "9.6.3",
+// "10.4 (Debian 10.4-2.pgdg90+1)" or "11beta1".
+func parseVersion(version string) [3]int {
+	var parsed [3]int
+	match := versionMatcher.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return parsed
+	}
+	for i := range parsed {
+		parsed[i], _ = strconv.Atoi(match[i+1])
+	}
+	return parsed
+}
+
 func (p *Postgres) collectCustomMetrics(db *sql.DB, agg metric.Aggregator) error {
 	for _, ms := range p.CustomMetrics {
 		for k, v := range ms.Metrics {
@@ -602,6 +777,114 @@ func (p *Postgres) collectStats(
 	return nil
 }
 
+// collectStatementMetrics collects the top queries by total time from
+// pg_stat_statements, when the extension is installed in the database.
+// The statistics are cumulative since they were last reset.
+func (p *Postgres) collectStatementMetrics(db *sql.DB, agg metric.Aggregator) error {
+	var installed int
+	err := db.QueryRow(statementsExtensionQuery).Scan(&installed)
+	if err != nil {
+		log.Errorf("Failed to execute query. %s", statementsExtensionQuery)
+		return err
+	}
+	if installed == 0 {
+		log.Debugf("pg_stat_statements is not installed, skipping the query metrics")
+		return nil
+	}
+
+	limit := p.StatementsLimit
+	if limit <= 0 {
+		limit = defaultStatementsLimit
+	}
+
+	queryID := "NULL::bigint"
+	if p.versionAtLeast("9.4.0") {
+		queryID = "s.queryid"
+	}
+	totalTime := "total_time"
+	if p.versionAtLeast("13.0.0") {
+		totalTime = "total_exec_time"
+	}
+	query := fmt.Sprintf(statementsQuery, queryID, totalTime)
+
+	log.Debugf("Running query: %s", query)
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		// e.g. the extension is created but pg_stat_statements isn't in
+		// shared_preload_libraries, which shouldn't fail the whole check.
+		log.Warnf("Failed to collect the query metrics: %s", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var id sql.NullInt64
+	var datname, rolname string
+	var text sql.NullString
+	var calls, total, rowCount, sharedHit, sharedRead, tempRead, tempWritten float64
+
+	for rows.Next() {
+		err = rows.Scan(&datname, &rolname, &id, &text, &calls, &total, &rowCount,
+			&sharedHit, &sharedRead, &tempRead, &tempWritten)
+		if err != nil {
+			return err
+		}
+
+		// The queries of the other users are hidden without the privilege,
+		// and the text is NULL when the query file can't be read.
+		if !text.Valid || text.String == "<insufficient privilege>" {
+			continue
+		}
+
+		signature := strconv.FormatInt(id.Int64, 10)
+		if !id.Valid {
+			signature = strconv.FormatUint(uint64(util.Hash(text.String)), 10)
+		}
+
+		tags := make([]string, 0, len(p.Tags)+4)
+		tags = append(tags, p.Tags...)
+		tags = append(tags,
+			"db:"+datname,
+			"user:"+rolname,
+			"query_signature:"+signature,
+			"query:"+normalizeQuery(text.String),
+		)
+
+		values := map[string]float64{
+			"calls":             calls,
+			"total_time":        total,
+			"rows":              rowCount,
+			"shared_blks_hit":   sharedHit,
+			"shared_blks_read":  sharedRead,
+			"temp_blks_read":    tempRead,
+			"temp_blks_written": tempWritten,
+		}
+		if calls > 0 {
+			values["mean_time"] = total / calls
+		}
+		for col, value := range values {
+			field := statementMetrics[col]
+			agg.Add(field.Type, metric.NewMetric(field.Name, value, tags))
+		}
+	}
+
+	return rows.Err()
+}
+
+// normalizeQuery collapses the whitespaces of the query and truncates it, the
+// constants are already replaced by placeholders in pg_stat_statements.
+func normalizeQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxQueryTextLength {
+		// Cut on a rune boundary to keep the tag valid UTF-8.
+		cut := maxQueryTextLength - 3
+		for cut > 0 && !utf8.RuneStart(query[cut]) {
+			cut--
+		}
+		query = query[:cut] + "..."
+	}
+	return query
+}
+
 var passwordKVMatcher, _ = regexp.Compile("password=\\S+ ?")
 
 func (p *Postgres) formatAddress() error {
@@ -0,0 +1,241 @@
+package redis
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+// The number of hash slots of a Redis Cluster.
+const clusterSlots = 16384
+
+var clusterInfoGauges = map[string]string{
+	"cluster_slots_assigned": "redis.cluster.slots_assigned",
+	"cluster_slots_ok":       "redis.cluster.slots_ok",
+	"cluster_slots_pfail":    "redis.cluster.slots_pfail",
+	"cluster_slots_fail":     "redis.cluster.slots_fail",
+	"cluster_known_nodes":    "redis.cluster.known_nodes",
+	"cluster_size":           "redis.cluster.size",
+	"cluster_current_epoch":  "redis.cluster.current_epoch",
+}
+
+// clusterNode is a line of CLUSTER NODES, which looks like:
+// <id> <ip:port@cport> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot> ...
+type clusterNode struct {
+	ID        string
+	Host      string
+	Port      string
+	Flags     []string
+	Connected bool
+	Slots     int
+}
+
+func (n clusterNode) hasFlag(flag string) bool {
+	for _, f := range n.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func (n clusterNode) role() string {
+	if n.hasFlag("master") {
+		return "master"
+	}
+	if n.hasFlag("slave") {
+		return "slave"
+	}
+	return "unknown"
+}
+
+func (n clusterNode) address() string {
+	return net.JoinHostPort(n.Host, n.Port)
+}
+
+func (r *Redis) collectClusterMetrics(c redis.Conn, tags []string, agg metric.Aggregator) error {
+	info, err := redis.String(c.Do("CLUSTER", "INFO"))
+	if err != nil {
+		log.Errorf("Failed to run cluster info command. %s", err.Error())
+		return err
+	}
+
+	fields := make(map[string]interface{})
+	for _, line := range strings.Split(info, "\n") {
+		record := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(record) < 2 {
+			continue
+		}
+		key, value := record[0], record[1]
+
+		if key == "cluster_state" {
+			state := 0
+			if value == "ok" {
+				state = 1
+			}
+			fields["redis.cluster.state"] = state
+			continue
+		}
+
+		name, ok := clusterInfoGauges[key]
+		if !ok {
+			continue
+		}
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		fields[name] = val
+		if key == "cluster_slots_ok" {
+			fields["redis.cluster.slots_coverage"] = 100 * val / clusterSlots
+		}
+	}
+	for name, value := range fields {
+		agg.Add("gauge", metric.NewMetric(name, value, tags))
+	}
+
+	out, err := redis.String(c.Do("CLUSTER", "NODES"))
+	if err != nil {
+		log.Errorf("Failed to run cluster nodes command. %s", err.Error())
+		return err
+	}
+	nodes := parseClusterNodes(out)
+
+	for _, node := range nodes {
+		nodeTags := make([]string, 0, len(tags)+2)
+		nodeTags = append(nodeTags, tags...)
+		nodeTags = append(nodeTags, "cluster_node:"+node.address(), "role:"+node.role())
+
+		connected, failed, pfailed := 0, 0, 0
+		if node.Connected {
+			connected = 1
+		}
+		if node.hasFlag("fail") {
+			failed = 1
+		}
+		if node.hasFlag("fail?") {
+			pfailed = 1
+		}
+		agg.Add("gauge", metric.NewMetric("redis.cluster.node.slots", node.Slots, nodeTags))
+		agg.Add("gauge", metric.NewMetric("redis.cluster.node.connected", connected, nodeTags))
+		agg.Add("gauge", metric.NewMetric("redis.cluster.node.fail", failed, nodeTags))
+		agg.Add("gauge", metric.NewMetric("redis.cluster.node.pfail", pfailed, nodeTags))
+
+		r.checkClusterRoleChange(node, nodeTags, agg)
+	}
+
+	if r.ClusterDiscovery {
+		r.collectClusterMembers(nodes, agg)
+	}
+	return nil
+}
+
+// checkClusterRoleChange sends an event when a replica has been promoted, or
+// a master has been demoted after a failover.
+func (r *Redis) checkClusterRoleChange(node clusterNode, tags []string, agg metric.Aggregator) {
+	if r.clusterRoles == nil {
+		r.clusterRoles = make(map[string]string)
+	}
+
+	role := node.role()
+	lastRole, ok := r.clusterRoles[node.ID]
+	r.clusterRoles[node.ID] = role
+	if !ok || lastRole == role {
+		return
+	}
+
+	agg.AddEvent(metric.Event{
+		Title:          fmt.Sprintf("Redis Cluster node %s changed role from %s to %s", node.address(), lastRole, role),
+		Text:           fmt.Sprintf("Redis Cluster node %s (%s) is now a %s, it was a %s.", node.address(), node.ID, role, lastRole),
+		AlertType:      "warning",
+		AggregationKey: "redis_cluster:" + node.ID,
+		SourceTypeName: "redis",
+		Tags:           tags,
+	})
+}
+
+// collectClusterMembers collects the INFO metrics of the other nodes found
+// in the cluster, so that a single instance configured with a seed address
+// covers the whole cluster.
+func (r *Redis) collectClusterMembers(nodes []clusterNode, agg metric.Aggregator) {
+	for _, node := range nodes {
+		if node.hasFlag("myself") || node.hasFlag("fail") || node.hasFlag("noaddr") || node.hasFlag("handshake") {
+			continue
+		}
+
+		c, err := dial("tcp", node.address(), r.getOptions()...)
+		if err != nil {
+			log.Errorf("Failed to connect redis cluster node %s. %s", node.address(), err.Error())
+			continue
+		}
+
+		tags := make([]string, 0, len(r.Tags)+2)
+		tags = append(tags, r.Tags...)
+		tags = append(tags, "redis_host:"+node.Host, "redis_port:"+node.Port)
+		_, err = r.collectInfoMetrics(c, tags, agg)
+		if err != nil {
+			log.Errorf("Failed to collect metrics of redis cluster node %s. %s", node.address(), err.Error())
+		}
+		c.Close()
+	}
+}
+
+func parseClusterNodes(out string) []clusterNode {
+	var nodes []clusterNode
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+
+		// The address is ip:port@cport since 4.0, with the hostname appended
+		// after a comma since 7.0.
+		addr := strings.SplitN(fields[1], ",", 2)[0]
+		addr = strings.SplitN(addr, "@", 2)[0]
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			log.Debugf("Failed to parse the address of cluster node %s. %s", fields[0], err)
+			continue
+		}
+
+		node := clusterNode{
+			ID:        fields[0],
+			Host:      host,
+			Port:      port,
+			Flags:     strings.Split(fields[2], ","),
+			Connected: fields[7] == "connected",
+		}
+		for _, slot := range fields[8:] {
+			// The slots being imported or migrated look like [1234->-<id>].
+			if strings.HasPrefix(slot, "[") {
+				continue
+			}
+			node.Slots += countSlots(slot)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// countSlots counts the slots of a single slot or a range like 0-5460.
+func countSlots(slot string) int {
+	bounds := strings.SplitN(slot, "-", 2)
+	start, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return 0
+	}
+	if len(bounds) == 1 {
+		return 1
+	}
+	end, err := strconv.Atoi(bounds[1])
+	if err != nil || end < start {
+		return 0
+	}
+	return end - start + 1
+}
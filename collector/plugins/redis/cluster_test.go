@@ -0,0 +1,187 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+var (
+	clusterInfoResult = "cluster_state:ok\r\n" +
+		"cluster_slots_assigned:16384\r\n" +
+		"cluster_slots_ok:16384\r\n" +
+		"cluster_slots_pfail:0\r\n" +
+		"cluster_slots_fail:0\r\n" +
+		"cluster_known_nodes:3\r\n" +
+		"cluster_size:2\r\n" +
+		"cluster_current_epoch:6\r\n" +
+		"cluster_my_epoch:2\r\n" +
+		"cluster_stats_messages_sent:1483972\r\n"
+
+	clusterNodesResult = `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 master - 0 1426238316232 2 connected 5461-10922 [5461->-e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca]
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460 16383
+`
+
+	clusterNodesFailoverResult = `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 master - 0 1426238317239 7 connected 0-5460 16383
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 master - 0 1426238316232 2 connected 5461-10922
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master,fail - 1426238317741 1426238316232 1 disconnected
+`
+)
+
+func TestParseClusterNodes(t *testing.T) {
+	nodes := parseClusterNodes(clusterNodesResult)
+	require.Len(t, nodes, 3)
+
+	assert.Equal(t, "07c37dfeb235213a872192d90877d0cd55635b91", nodes[0].ID)
+	assert.Equal(t, "127.0.0.1:30004", nodes[0].address())
+	assert.Equal(t, "slave", nodes[0].role())
+	assert.Equal(t, 0, nodes[0].Slots)
+
+	assert.Equal(t, "master", nodes[1].role())
+	assert.Equal(t, 5462, nodes[1].Slots)
+	assert.True(t, nodes[1].Connected)
+
+	assert.True(t, nodes[2].hasFlag("myself"))
+	assert.Equal(t, 5462, nodes[2].Slots)
+
+	// The hostname is appended since 7.0.
+	nodes = parseClusterNodes("07c37dfe 10.0.0.4:6379@16379,redis-4 master - 0 0 4 connected 0-99\n")
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "10.0.0.4", nodes[0].Host)
+	assert.Equal(t, "6379", nodes[0].Port)
+	assert.Equal(t, 100, nodes[0].Slots)
+}
+
+func TestCollectClusterMetrics(t *testing.T) {
+	r := &Redis{}
+	tags := []string{"service:redis"}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	c := redigomock.NewConn()
+	c.Command("CLUSTER", "INFO").Expect(clusterInfoResult)
+	c.Command("CLUSTER", "NODES").Expect(clusterNodesResult)
+
+	err := r.collectClusterMetrics(c, tags, agg)
+	require.NoError(t, err)
+	agg.Flush()
+	expectedMetrics := 21
+	require.Len(t, metricC, expectedMetrics)
+
+	metrics := make([]metric.Metric, expectedMetrics)
+	for i := 0; i < expectedMetrics; i++ {
+		metrics[i] = <-metricC
+	}
+
+	fields := map[string]float64{
+		"redis.cluster.state":          1,
+		"redis.cluster.slots_assigned": 16384,
+		"redis.cluster.slots_ok":       16384,
+		"redis.cluster.slots_pfail":    0,
+		"redis.cluster.slots_fail":     0,
+		"redis.cluster.slots_coverage": 100,
+		"redis.cluster.known_nodes":    3,
+		"redis.cluster.size":           2,
+		"redis.cluster.current_epoch":  6,
+	}
+	for name, value := range fields {
+		testutil.AssertContainsMetricWithTags(t, metrics, name, value, tags)
+	}
+
+	fields = map[string]float64{
+		"redis.cluster.node.slots":     5462,
+		"redis.cluster.node.connected": 1,
+		"redis.cluster.node.fail":      0,
+		"redis.cluster.node.pfail":     0,
+	}
+	nodeTags := []string{"service:redis", "cluster_node:127.0.0.1:30001", "role:master"}
+	for name, value := range fields {
+		testutil.AssertContainsMetricWithTags(t, metrics, name, value, nodeTags)
+	}
+}
+
+func TestClusterFailoverEvent(t *testing.T) {
+	r := &Redis{}
+	tags := []string{"service:redis"}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	c := redigomock.NewConn()
+	c.Command("CLUSTER", "INFO").Expect(clusterInfoResult)
+	c.Command("CLUSTER", "NODES").Expect(clusterNodesResult)
+	require.NoError(t, r.collectClusterMetrics(c, tags, agg))
+	agg.Flush()
+	for len(metricC) > 0 {
+		<-metricC
+	}
+
+	c.Clear()
+	c.Command("CLUSTER", "INFO").Expect(clusterInfoResult)
+	c.Command("CLUSTER", "NODES").Expect(clusterNodesFailoverResult)
+	require.NoError(t, r.collectClusterMetrics(c, tags, agg))
+	agg.Flush()
+
+	var events []metric.Event
+	for len(metricC) > 0 {
+		m := <-metricC
+		if e, ok := m.Value.(metric.Event); ok {
+			events = append(events, e)
+		}
+	}
+	require.Len(t, events, 1)
+	assert.Equal(t, "Redis Cluster node 127.0.0.1:30004 changed role from slave to master", events[0].Title)
+	assert.Equal(t, "redis", events[0].SourceTypeName)
+	assert.Equal(t, []string{"service:redis", "cluster_node:127.0.0.1:30004", "role:master"}, events[0].Tags)
+}
+
+func TestCollectClusterMembers(t *testing.T) {
+	defer func() {
+		dial = redis.Dial
+	}()
+
+	var addresses []string
+	dial = func(network, address string, options ...redis.DialOption) (redis.Conn, error) {
+		addresses = append(addresses, address)
+		c := redigomock.NewConn()
+		c.Command("INFO", "ALL").Expect("# Clients\r\nconnected_clients:7\r\n")
+		return c, nil
+	}
+
+	r := &Redis{
+		Tags:             []string{"service:redis"},
+		ClusterDiscovery: true,
+	}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	r.collectClusterMembers(parseClusterNodes(clusterNodesResult), agg)
+	agg.Flush()
+	// The node itself is skipped.
+	assert.Equal(t, []string{"127.0.0.1:30004", "127.0.0.1:30002"}, addresses)
+
+	var metrics []metric.Metric
+	for len(metricC) > 0 {
+		metrics = append(metrics, <-metricC)
+	}
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.net.clients", 7,
+		[]string{"service:redis", "redis_host:127.0.0.1", "redis_port:30004"})
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.net.clients", 7,
+		[]string{"service:redis", "redis_host:127.0.0.1", "redis_port:30002"})
+}
+
+func TestCountSlots(t *testing.T) {
+	assert.Equal(t, 1, countSlots("16383"))
+	assert.Equal(t, 5461, countSlots("0-5460"))
+	assert.Equal(t, 0, countSlots("5460-0"))
+	assert.Equal(t, 0, countSlots("abc"))
+}
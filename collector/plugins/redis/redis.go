@@ -21,6 +21,8 @@ import (
 func NewRedis(conf plugin.InitConfig) plugin.Plugin {
 	return &Redis{
 		lastTimestampSeen: make(map[instance]int64),
+		clusterRoles:      make(map[string]string),
+		sentinelMasters:   make(map[string]string),
 	}
 }
 
@@ -36,8 +38,15 @@ type Redis struct {
 	Keys              []string
 	WarnOnMissingKeys bool    `yaml:"warn_on_missing_keys"`
 	SlowlogMaxLen     float64 `yaml:"slowlog-max-len"`
+	ClusterDiscovery  bool    `yaml:"cluster_discovery"`
+	Sentinel          bool
+	ClientMetrics     bool `yaml:"client_metrics"`
 
 	lastTimestampSeen map[instance]int64
+	// The roles of the cluster nodes by ID, and the addresses of the
+	// masters monitored by Sentinel by name, to detect the failovers.
+	clusterRoles    map[string]string
+	sentinelMasters map[string]string
 }
 
 type instance [2]string
@@ -118,7 +127,7 @@ func (r *Redis) Check(agg metric.Aggregator) error {
 	}
 
 	options := r.getOptions()
-	c, err := redis.Dial(network, target, options...)
+	c, err := dial(network, target, options...)
 	if err != nil {
 		log.Errorf("Failed to connect redis. %s", err.Error())
 		return err
@@ -126,6 +135,10 @@ func (r *Redis) Check(agg metric.Aggregator) error {
 	defer c.Close()
 
 	tags := r.getTags()
+	if r.Sentinel {
+		return r.collectSentinelMetrics(c, tags, agg)
+	}
+
 	err = r.collectMetrics(c, tags, agg)
 	if err != nil {
 		return err
@@ -133,8 +146,11 @@ func (r *Redis) Check(agg metric.Aggregator) error {
 	return nil
 }
 
+// dial is a variable for test reason.
+var dial = redis.Dial
+
 func (r *Redis) collectMetrics(c redis.Conn, tags []string, agg metric.Aggregator) error {
-	err := r.collectInfoMetrics(c, tags, agg)
+	info, err := r.collectInfoMetrics(c, tags, agg)
 	if err != nil {
 		return err
 	}
@@ -145,15 +161,30 @@ func (r *Redis) collectMetrics(c redis.Conn, tags []string, agg metric.Aggregato
 	if err != nil {
 		return err
 	}
+
+	r.collectLatency(c, tags, agg)
+	r.collectMemoryStats(c, tags, agg)
+
+	if r.ClientMetrics {
+		r.collectClientMetrics(c, tags, agg)
+	}
+
+	if info["cluster_enabled"] == "1" {
+		err = r.collectClusterMetrics(c, tags, agg)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (r *Redis) collectInfoMetrics(c redis.Conn, tags []string, agg metric.Aggregator) error {
+// collectInfoMetrics returns the fields of INFO, after collecting the metrics.
+func (r *Redis) collectInfoMetrics(c redis.Conn, tags []string, agg metric.Aggregator) (map[string]string, error) {
 	start := time.Now()
 	info, err := redis.String(c.Do("INFO", "ALL"))
 	if err != nil {
 		log.Errorf("Failed to run info command. %s", err.Error())
-		return err
+		return nil, err
 	}
 	elapsed := time.Since(start)
 	latencyMs := util.Round(float64(elapsed)/float64(time.Millisecond), 2)
@@ -169,6 +200,7 @@ func (r *Redis) collectInfoMetrics(c redis.Conn, tags []string, agg metric.Aggre
 		lines = strings.Split(info, "\n")
 	}
 
+	fields := make(map[string]string)
 	for _, line := range lines {
 		if line == "" {
 			continue
@@ -182,12 +214,18 @@ func (r *Redis) collectInfoMetrics(c redis.Conn, tags []string, agg metric.Aggre
 			continue
 		}
 		key, value := record[0], record[1]
+		fields[key] = value
 
 		if re, _ := regexp.MatchString(`^db\d+`, key); re {
 			r.collectDBMetrics(key, value, tags, agg)
 			continue
 		}
 
+		if strings.HasPrefix(key, "cmdstat_") {
+			r.collectCommandStats(strings.TrimPrefix(key, "cmdstat_"), value, tags, agg)
+			continue
+		}
+
 		val, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			continue
@@ -211,7 +249,7 @@ func (r *Redis) collectInfoMetrics(c redis.Conn, tags []string, agg metric.Aggre
 	}
 
 	r.collectReplicaMetrics(lines, tags, agg)
-	return nil
+	return fields, nil
 }
 
 func (r *Redis) collectDBMetrics(key, value string, tags []string, agg metric.Aggregator) {
@@ -241,6 +279,34 @@ func (r *Redis) collectDBMetrics(key, value string, tags []string, agg metric.Ag
 	agg.AddMetrics("gauge", "redis", fields, dbTags, "")
 }
 
+// collectCommandStats parses a line of the commandstats section, which looks like:
+// cmdstat_set:calls=7,usec=37,usec_per_call=5.29
+func (r *Redis) collectCommandStats(command, value string, tags []string, agg metric.Aggregator) {
+	commandTags := make([]string, 0, len(tags)+1)
+	commandTags = append(commandTags, tags...)
+	commandTags = append(commandTags, "command:"+command)
+
+	for _, kv := range strings.Split(value, ",") {
+		split := strings.SplitN(kv, "=", 2)
+		if len(split) != 2 {
+			continue
+		}
+		val, err := strconv.ParseFloat(split[1], 64)
+		if err != nil {
+			continue
+		}
+
+		switch split[0] {
+		case "calls":
+			agg.Add("rate", metric.NewMetric("redis.command.calls", val, commandTags))
+		case "usec":
+			agg.Add("rate", metric.NewMetric("redis.command.usec", val, commandTags))
+		case "usec_per_call":
+			agg.Add("gauge", metric.NewMetric("redis.command.usec_per_call", val, commandTags))
+		}
+	}
+}
+
 func (r *Redis) collectReplicaMetrics(lines, tags []string, agg metric.Aggregator) {
 	var masterDownSeconds, masterOffset, slaveOffset float64
 	var masterStatus, slaveID, ip, port string
@@ -415,6 +481,158 @@ func (r *Redis) collectSlowlog(c redis.Conn, tags []string, agg metric.Aggregato
 	return nil
 }
 
+// collectLatency collects the latest latency spikes of the events sampled by
+// the latency monitor, which is enabled by latency-monitor-threshold.
+func (r *Redis) collectLatency(c redis.Conn, tags []string, agg metric.Aggregator) {
+	events, err := redis.Values(c.Do("LATENCY", "LATEST"))
+	if err != nil {
+		log.Debugf("Failed to run latency latest command. %s", err)
+		return
+	}
+
+	for _, e := range events {
+		// event name, timestamp, latest latency, max latency
+		entry, ok := e.([]interface{})
+		if !ok || len(entry) != 4 {
+			continue
+		}
+		name, err := redis.String(entry[0], nil)
+		if err != nil {
+			continue
+		}
+		latest, err := redis.Int64(entry[2], nil)
+		if err != nil {
+			continue
+		}
+		max, err := redis.Int64(entry[3], nil)
+		if err != nil {
+			continue
+		}
+
+		eventTags := make([]string, 0, len(tags)+1)
+		eventTags = append(eventTags, tags...)
+		eventTags = append(eventTags, "event:"+name)
+		agg.Add("gauge", metric.NewMetric("redis.latency.latest_ms", latest, eventTags))
+		agg.Add("gauge", metric.NewMetric("redis.latency.max_ms", max, eventTags))
+	}
+}
+
+// collectMemoryStats collects the numeric fields of MEMORY STATS, available
+// since 4.0. The overhead of each db is tagged by redis_db.
+func (r *Redis) collectMemoryStats(c redis.Conn, tags []string, agg metric.Aggregator) {
+	stats, err := redis.Values(c.Do("MEMORY", "STATS"))
+	if err != nil {
+		log.Debugf("Failed to run memory stats command. %s", err)
+		return
+	}
+
+	for i := 0; i+1 < len(stats); i += 2 {
+		key, err := redis.String(stats[i], nil)
+		if err != nil {
+			continue
+		}
+
+		if nested, ok := stats[i+1].([]interface{}); ok {
+			if !strings.HasPrefix(key, "db.") {
+				continue
+			}
+			dbTags := make([]string, 0, len(tags)+1)
+			dbTags = append(dbTags, tags...)
+			dbTags = append(dbTags, "redis_db:db"+strings.TrimPrefix(key, "db."))
+			for j := 0; j+1 < len(nested); j += 2 {
+				name, err := redis.String(nested[j], nil)
+				if err != nil {
+					continue
+				}
+				if val, ok := parseStatValue(nested[j+1]); ok {
+					agg.Add("gauge", metric.NewMetric("redis.mem.stats.db."+memoryStatName(name), val, dbTags))
+				}
+			}
+			continue
+		}
+
+		if val, ok := parseStatValue(stats[i+1]); ok {
+			agg.Add("gauge", metric.NewMetric("redis.mem.stats."+memoryStatName(key), val, tags))
+		}
+	}
+}
+
+func memoryStatName(key string) string {
+	return strings.Replace(key, "-", "_", -1)
+}
+
+// parseStatValue parses the integers, and the ratios which are returned as
+// bulk strings.
+func parseStatValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case []byte:
+		val, err := strconv.ParseFloat(string(v), 64)
+		return val, err == nil
+	}
+	return 0, false
+}
+
+// The flags of CLIENT LIST, see https://redis.io/commands/client-list
+var clientFlags = map[rune]string{
+	'N': "normal",
+	'S': "replica",
+	'M': "master",
+	'P': "pubsub",
+	'O': "monitor",
+	'x': "multi",
+	'b': "blocked",
+	'c': "close_after_reply",
+	'd': "dirty",
+	'u': "unblocked",
+	'U': "unix_socket",
+	'r': "readonly",
+}
+
+// collectClientMetrics collects the clients by flag, and the distribution of
+// their idle time. CLIENT LIST is O(N) with the number of clients.
+func (r *Redis) collectClientMetrics(c redis.Conn, tags []string, agg metric.Aggregator) {
+	list, err := redis.String(c.Do("CLIENT", "LIST"))
+	if err != nil {
+		log.Warnf("Failed to run client list command. %s", err)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := make(map[string]string)
+		for _, kv := range strings.Fields(line) {
+			split := strings.SplitN(kv, "=", 2)
+			if len(split) == 2 {
+				fields[split[0]] = split[1]
+			}
+		}
+
+		for _, flag := range fields["flags"] {
+			if name, ok := clientFlags[flag]; ok {
+				counts[name]++
+			}
+		}
+
+		if idle, err := strconv.ParseFloat(fields["idle"], 64); err == nil {
+			agg.Add("histogram", metric.NewMetric("redis.clients.idle", idle, tags))
+		}
+	}
+
+	for name, count := range counts {
+		flagTags := make([]string, 0, len(tags)+1)
+		flagTags = append(flagTags, tags...)
+		flagTags = append(flagTags, "client_flag:"+name)
+		agg.Add("gauge", metric.NewMetric("redis.clients.count", count, flagTags))
+	}
+}
+
 func (r *Redis) getOptions() []redis.DialOption {
 	var options []redis.DialOption
 	if r.DB > 0 {
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/rafaeljusto/redigomock"
@@ -206,7 +207,7 @@ func TestCollectMetrics(t *testing.T) {
 	err = r.collectMetrics(c, r.Tags, agg)
 	require.NoError(t, err)
 	agg.Flush()
-	expectedMetrics := 48
+	expectedMetrics := 56
 	require.Len(t, metricC, expectedMetrics)
 
 	metrics := make([]metric.Metric, expectedMetrics)
@@ -290,6 +291,12 @@ func TestCollectMetrics(t *testing.T) {
 	testutil.AssertContainsMetricWithTags(t, metrics, "redis.key.length", 6, []string{"service:redis", "key:sset"})
 	testutil.AssertContainsMetricWithTags(t, metrics, "redis.key.length", 0, []string{"service:redis", "key:string"})
 
+	// commandstats, the calls and usec are rates
+	tags = []string{"service:redis", "command:info"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.command.usec_per_call", 79.6, tags)
+	tags = []string{"service:redis", "command:set"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.command.usec_per_call", 5.29, tags)
+
 	// slowlog
 	fields = map[string]float64{
 		"redis.slowlog.micros.95percentile": 60,
@@ -328,6 +335,120 @@ func TestCollectMetrics(t *testing.T) {
 	}
 }
 
+func TestCollectCommandStats(t *testing.T) {
+	r := Redis{}
+	tags := []string{"service:redis", "command:client|list"}
+	fields := map[string]float64{
+		"redis.command.calls":         4,
+		"redis.command.usec":          40,
+		"redis.command.usec_per_call": 10,
+	}
+	calls := 3
+	check := func(agg metric.Aggregator) error {
+		calls += 4
+		value := fmt.Sprintf("calls=%d,usec=%d,usec_per_call=10.00,rejected_calls=0,failed_calls=0", calls, calls*10)
+		r.collectCommandStats("client|list", value, []string{"service:redis"}, agg)
+		return nil
+	}
+	testutil.AssertCheckWithRateMetrics(t, check, check, 3, fields, tags, 0.01)
+}
+
+func TestCollectLatency(t *testing.T) {
+	r := Redis{}
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	c := redigomock.NewConn()
+	c.Command("LATENCY", "LATEST").Expect([]interface{}{
+		[]interface{}{[]byte("command"), int64(1405067976), int64(251), int64(1001)},
+		[]interface{}{[]byte("fast-command"), int64(1405067822), int64(3), int64(8)},
+	})
+	r.collectLatency(c, []string{"service:redis"}, agg)
+	agg.Flush()
+	require.Len(t, metricC, 4)
+
+	metrics := make([]metric.Metric, 4)
+	for i := range metrics {
+		metrics[i] = <-metricC
+	}
+	tags := []string{"service:redis", "event:command"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.latency.latest_ms", 251, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.latency.max_ms", 1001, tags)
+	tags = []string{"service:redis", "event:fast-command"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.latency.latest_ms", 3, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.latency.max_ms", 8, tags)
+}
+
+func TestCollectMemoryStats(t *testing.T) {
+	r := Redis{}
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	c := redigomock.NewConn()
+	c.Command("MEMORY", "STATS").Expect([]interface{}{
+		[]byte("peak.allocated"), int64(1993112),
+		[]byte("total.allocated"), int64(1952056),
+		[]byte("db.0"), []interface{}{
+			[]byte("overhead.hashtable.main"), int64(72),
+			[]byte("overhead.hashtable.expires"), int64(0),
+		},
+		[]byte("keys.bytes-per-key"), int64(512),
+		[]byte("dataset.percentage"), []byte("32.5"),
+		[]byte("allocator-stats"), []byte("jemalloc"),
+	})
+	r.collectMemoryStats(c, []string{"service:redis"}, agg)
+	agg.Flush()
+	require.Len(t, metricC, 6)
+
+	metrics := make([]metric.Metric, 6)
+	for i := range metrics {
+		metrics[i] = <-metricC
+	}
+	tags := []string{"service:redis"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.mem.stats.peak.allocated", 1993112, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.mem.stats.total.allocated", 1952056, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.mem.stats.keys.bytes_per_key", 512, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.mem.stats.dataset.percentage", 32.5, tags)
+	tags = []string{"service:redis", "redis_db:db0"}
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.mem.stats.db.overhead.hashtable.main", 72, tags)
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.mem.stats.db.overhead.hashtable.expires", 0, tags)
+}
+
+func TestCollectClientMetrics(t *testing.T) {
+	r := Redis{ClientMetrics: true}
+	metricC := make(chan metric.Metric, 20)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	c := redigomock.NewConn()
+	c.Command("CLIENT", "LIST").Expect(
+		"id=3 addr=127.0.0.1:52555 fd=8 name= age=855 idle=0 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=0 cmd=client\n" +
+			"id=4 addr=127.0.0.1:52787 fd=9 name= age=60 idle=20 flags=b db=0 sub=0 psub=0 multi=-1 qbuf=0 cmd=blpop\n" +
+			"id=5 addr=172.17.0.3:6379 fd=10 name= age=3600 idle=1 flags=S db=0 sub=0 psub=0 multi=-1 qbuf=0 cmd=replconf\n" +
+			"id=6 addr=127.0.0.1:52790 fd=11 name= age=120 idle=100 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=0 cmd=get\n")
+	r.collectClientMetrics(c, []string{"service:redis"}, agg)
+	agg.Flush()
+	require.Len(t, metricC, 8)
+
+	metrics := make([]metric.Metric, 8)
+	for i := range metrics {
+		metrics[i] = <-metricC
+	}
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.clients.count", 2, []string{"service:redis", "client_flag:normal"})
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.clients.count", 1, []string{"service:redis", "client_flag:blocked"})
+	testutil.AssertContainsMetricWithTags(t, metrics, "redis.clients.count", 1, []string{"service:redis", "client_flag:replica"})
+
+	fields := map[string]float64{
+		"redis.clients.idle.max":   100,
+		"redis.clients.idle.count": 4,
+	}
+	for name, value := range fields {
+		testutil.AssertContainsMetricWithTags(t, metrics, name, value, []string{"service:redis"})
+	}
+}
+
 func TestGetOptions(t *testing.T) {
 	r := Redis{
 		DB:            1,
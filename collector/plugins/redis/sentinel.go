@@ -0,0 +1,133 @@
+package redis
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+var sentinelMasterGauges = map[string]string{
+	"quorum":              "redis.sentinel.quorum",
+	"num-slaves":          "redis.sentinel.replicas",
+	"num-other-sentinels": "redis.sentinel.other_sentinels",
+}
+
+// The flags of the masters reported as gauges of 0 or 1.
+var sentinelMasterFlags = map[string]string{
+	"s_down":               "redis.sentinel.master.sdown",
+	"o_down":               "redis.sentinel.master.odown",
+	"failover_in_progress": "redis.sentinel.master.failover_in_progress",
+}
+
+// collectSentinelMetrics collects the state of the masters monitored by a
+// Sentinel, which doesn't support most of the commands of a Redis server.
+func (r *Redis) collectSentinelMetrics(c redis.Conn, tags []string, agg metric.Aggregator) error {
+	masters, err := redis.Values(c.Do("SENTINEL", "MASTERS"))
+	if err != nil {
+		log.Errorf("Failed to run sentinel masters command. %s", err.Error())
+		return err
+	}
+
+	for _, m := range masters {
+		master, err := redis.StringMap(m, nil)
+		if err != nil {
+			log.Warnf("Failed to parse sentinel master. %s", err)
+			continue
+		}
+		name := master["name"]
+
+		masterTags := make([]string, 0, len(tags)+1)
+		masterTags = append(masterTags, tags...)
+		masterTags = append(masterTags, "sentinel_master:"+name)
+
+		for key, metricName := range sentinelMasterGauges {
+			val, err := strconv.ParseFloat(master[key], 64)
+			if err != nil {
+				continue
+			}
+			agg.Add("gauge", metric.NewMetric(metricName, val, masterTags))
+		}
+
+		flags := strings.Split(master["flags"], ",")
+		for flag, metricName := range sentinelMasterFlags {
+			val := 0
+			for _, f := range flags {
+				if f == flag {
+					val = 1
+					break
+				}
+			}
+			agg.Add("gauge", metric.NewMetric(metricName, val, masterTags))
+		}
+
+		replicas, err := r.getSentinelReplicas(c, name)
+		if err != nil {
+			log.Warnf("Failed to get the replicas of sentinel master %s. %s", name, err)
+		} else {
+			down := 0
+			for _, replica := range replicas {
+				for _, f := range strings.Split(replica["flags"], ",") {
+					if f == "s_down" || f == "o_down" || f == "disconnected" {
+						down++
+						break
+					}
+				}
+			}
+			agg.Add("gauge", metric.NewMetric("redis.sentinel.replicas_down", down, masterTags))
+		}
+
+		r.checkSentinelFailover(name, net.JoinHostPort(master["ip"], master["port"]), masterTags, agg)
+	}
+	return nil
+}
+
+// getSentinelReplicas runs SENTINEL REPLICAS, which is named SENTINEL SLAVES
+// before 5.0.
+func (r *Redis) getSentinelReplicas(c redis.Conn, master string) ([]map[string]string, error) {
+	values, err := redis.Values(c.Do("SENTINEL", "REPLICAS", master))
+	if err != nil {
+		values, err = redis.Values(c.Do("SENTINEL", "SLAVES", master))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	replicas := make([]map[string]string, 0, len(values))
+	for _, v := range values {
+		replica, err := redis.StringMap(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas, nil
+}
+
+// checkSentinelFailover sends an event when the address of a master changes,
+// i.e. a replica has been promoted by the Sentinels.
+func (r *Redis) checkSentinelFailover(name, addr string, tags []string, agg metric.Aggregator) {
+	if r.sentinelMasters == nil {
+		r.sentinelMasters = make(map[string]string)
+	}
+
+	lastAddr, ok := r.sentinelMasters[name]
+	r.sentinelMasters[name] = addr
+	if !ok || lastAddr == addr {
+		return
+	}
+
+	agg.AddEvent(metric.Event{
+		Title:          fmt.Sprintf("Redis Sentinel failed over master %s from %s to %s", name, lastAddr, addr),
+		Text:           fmt.Sprintf("The master %s is now %s, it was %s.", name, addr, lastAddr),
+		AlertType:      "warning",
+		AggregationKey: "redis_sentinel:" + name,
+		SourceTypeName: "redis",
+		Tags:           tags,
+	})
+}
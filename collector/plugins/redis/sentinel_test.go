@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+func sentinelMaster(ip, flags string) []interface{} {
+	return []interface{}{
+		[]byte("name"), []byte("mymaster"),
+		[]byte("ip"), []byte(ip),
+		[]byte("port"), []byte("6379"),
+		[]byte("flags"), []byte(flags),
+		[]byte("num-slaves"), []byte("2"),
+		[]byte("num-other-sentinels"), []byte("2"),
+		[]byte("quorum"), []byte("2"),
+	}
+}
+
+var sentinelReplicasResult = []interface{}{
+	[]interface{}{
+		[]byte("name"), []byte("10.0.0.2:6379"),
+		[]byte("flags"), []byte("slave"),
+	},
+	[]interface{}{
+		[]byte("name"), []byte("10.0.0.3:6379"),
+		[]byte("flags"), []byte("s_down,slave,disconnected"),
+	},
+}
+
+func TestCollectSentinelMetrics(t *testing.T) {
+	r := &Redis{}
+	tags := []string{"service:redis"}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	c := redigomock.NewConn()
+	c.Command("SENTINEL", "MASTERS").Expect([]interface{}{sentinelMaster("10.0.0.1", "master,o_down,s_down")})
+	c.Command("SENTINEL", "REPLICAS", "mymaster").Expect(sentinelReplicasResult)
+
+	err := r.collectSentinelMetrics(c, tags, agg)
+	require.NoError(t, err)
+	agg.Flush()
+	expectedMetrics := 7
+	require.Len(t, metricC, expectedMetrics)
+
+	metrics := make([]metric.Metric, expectedMetrics)
+	for i := 0; i < expectedMetrics; i++ {
+		metrics[i] = <-metricC
+	}
+
+	fields := map[string]float64{
+		"redis.sentinel.quorum":                      2,
+		"redis.sentinel.replicas":                    2,
+		"redis.sentinel.other_sentinels":             2,
+		"redis.sentinel.replicas_down":               1,
+		"redis.sentinel.master.sdown":                1,
+		"redis.sentinel.master.odown":                1,
+		"redis.sentinel.master.failover_in_progress": 0,
+	}
+	masterTags := []string{"service:redis", "sentinel_master:mymaster"}
+	for name, value := range fields {
+		testutil.AssertContainsMetricWithTags(t, metrics, name, value, masterTags)
+	}
+}
+
+func TestSentinelFailoverEvent(t *testing.T) {
+	r := &Redis{}
+	tags := []string{"service:redis"}
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	// SENTINEL REPLICAS is named SENTINEL SLAVES before 5.0.
+	c := redigomock.NewConn()
+	c.Command("SENTINEL", "MASTERS").Expect([]interface{}{sentinelMaster("10.0.0.1", "master")})
+	c.Command("SENTINEL", "REPLICAS", "mymaster").ExpectError(errors.New("ERR Unknown sentinel subcommand 'replicas'"))
+	c.Command("SENTINEL", "SLAVES", "mymaster").Expect(sentinelReplicasResult)
+	require.NoError(t, r.collectSentinelMetrics(c, tags, agg))
+	agg.Flush()
+	for len(metricC) > 0 {
+		m := <-metricC
+		if m.Name == "redis.sentinel.replicas_down" {
+			assert.EqualValues(t, 1, m.Value)
+		}
+	}
+
+	c.Command("SENTINEL", "MASTERS").Expect([]interface{}{sentinelMaster("10.0.0.2", "master")})
+	require.NoError(t, r.collectSentinelMetrics(c, tags, agg))
+	agg.Flush()
+
+	var events []metric.Event
+	for len(metricC) > 0 {
+		m := <-metricC
+		if e, ok := m.Value.(metric.Event); ok {
+			events = append(events, e)
+		}
+	}
+	require.Len(t, events, 1)
+	assert.Equal(t, "Redis Sentinel failed over master mymaster from 10.0.0.1:6379 to 10.0.0.2:6379", events[0].Title)
+	assert.Equal(t, []string{"service:redis", "sentinel_master:mymaster"}, events[0].Tags)
+}
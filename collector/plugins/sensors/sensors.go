@@ -0,0 +1,201 @@
+package sensors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+	"github.com/cloudinsight/cloudinsight-agent/common/util"
+)
+
+// NewSensors XXX
+func NewSensors(conf plugin.InitConfig) plugin.Plugin {
+	return &Sensors{}
+}
+
+// Sensors collects the hardware sensors of the hwmon drivers and the thermal
+// zones from sysfs.
+type Sensors struct {
+	SysRoot string `yaml:"sys_root"`
+	Tags    []string
+}
+
+const defaultSysRoot = "/sys"
+
+// sensorType describes the readings of a type of hwmon sensor, which are
+// reported in the unit of the metric divided by the scale.
+type sensorType struct {
+	name  string
+	scale float64
+}
+
+var (
+	// https://www.kernel.org/doc/Documentation/hwmon/sysfs-interface
+	sensorTypes = map[string]sensorType{
+		"temp":  {"sensors.temperature", 1000}, // millidegree Celsius
+		"fan":   {"sensors.fan_speed", 1},      // RPM
+		"in":    {"sensors.voltage", 1000},     // millivolt
+		"power": {"sensors.power", 1000000},    // microwatt
+	}
+
+	// The suffixes of the metrics by item, the input is the reading.
+	sensorItems = map[string]string{
+		"input": "",
+		"min":   ".min",
+		"max":   ".max",
+		"crit":  ".critical",
+	}
+
+	sensorFile = regexp.MustCompile(`^(temp|fan|in|power)(\d+)_(input|min|max|crit)$`)
+	tripPoint  = regexp.MustCompile(`^trip_point_(\d+)_type$`)
+)
+
+// Check XXX
+func (s *Sensors) Check(agg metric.Aggregator) error {
+	root := s.SysRoot
+	if root == "" {
+		root = defaultSysRoot
+	}
+
+	hwmonErr := s.collectHwmon(filepath.Join(root, "class", "hwmon"), agg)
+	thermalErr := s.collectThermal(filepath.Join(root, "class", "thermal"), agg)
+	if hwmonErr != nil && thermalErr != nil {
+		return fmt.Errorf("Unable to read the sensors in %s: %s", root, hwmonErr)
+	}
+	return nil
+}
+
+func (s *Sensors) collectHwmon(dir string, agg metric.Aggregator) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "hwmon") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		device := deviceName(path)
+		// Older drivers expose the attributes in the device directory.
+		if _, ok := util.ReadString(filepath.Join(path, "name")); !ok {
+			path = filepath.Join(path, "device")
+		}
+		s.collectChip(path, device, agg)
+	}
+	return nil
+}
+
+// collectChip collects the sensors of a hwmon device. Several devices may
+// have the same chip and labels, e.g. the coretemp of each CPU socket, so
+// they are told apart by the device.
+func (s *Sensors) collectChip(dir, device string, agg metric.Aggregator) {
+	chip, ok := util.ReadString(filepath.Join(dir, "name"))
+	if !ok {
+		return
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		match := sensorFile.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+		prefix, item := match[1]+match[2], match[3]
+
+		// The readings of missing sensors can't be read, e.g. EIO or ENXIO.
+		v, ok := util.ReadValue(filepath.Join(dir, file.Name()))
+		if !ok {
+			continue
+		}
+		if fault, ok := util.ReadValue(filepath.Join(dir, prefix+"_fault")); ok && fault != 0 {
+			continue
+		}
+
+		label, ok := util.ReadString(filepath.Join(dir, prefix+"_label"))
+		if !ok {
+			label = prefix
+		}
+
+		st := sensorTypes[match[1]]
+		name := st.name + sensorItems[item]
+		tags := []string{"chip:" + chip}
+		if device != "" {
+			tags = append(tags, "device:"+device)
+		}
+		tags = append(tags, "label:"+label)
+		agg.Add("gauge", metric.NewMetric(name, v/st.scale, s.withTags(tags...)))
+	}
+}
+
+// collectThermal collects the temperatures of the thermal zones, whose
+// critical trip point is reported as the critical threshold.
+func (s *Sensors) collectThermal(dir string, agg metric.Aggregator) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "thermal_zone") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		temp, ok := util.ReadValue(filepath.Join(path, "temp"))
+		if !ok {
+			continue
+		}
+		label, ok := util.ReadString(filepath.Join(path, "type"))
+		if !ok {
+			label = entry.Name()
+		}
+		tags := s.withTags("chip:"+entry.Name(), "label:"+label)
+		agg.Add("gauge", metric.NewMetric("sensors.temperature", temp/1000, tags))
+
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			match := tripPoint.FindStringSubmatch(file.Name())
+			if match == nil {
+				continue
+			}
+			if tripType, _ := util.ReadString(filepath.Join(path, file.Name())); tripType != "critical" {
+				continue
+			}
+			if v, ok := util.ReadValue(filepath.Join(path, "trip_point_"+match[1]+"_temp")); ok {
+				agg.Add("gauge", metric.NewMetric("sensors.temperature.critical", v/1000, tags))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Sensors) withTags(tags ...string) []string {
+	return append(append(make([]string, 0, len(s.Tags)+len(tags)), s.Tags...), tags...)
+}
+
+// deviceName returns the name of the device of a hwmon directory, e.g. the
+// PCI address 0000:00:18.3 or coretemp.0, which doesn't change across
+// reboots unlike the number of hwmonN. It's empty for the virtual devices.
+func deviceName(hwmonDir string) string {
+	target, err := filepath.EvalSymlinks(filepath.Join(hwmonDir, "device"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+func init() {
+	collector.Add("sensors", NewSensors)
+}
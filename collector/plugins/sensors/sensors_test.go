@@ -0,0 +1,65 @@
+package sensors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+func TestSensors(t *testing.T) {
+	s := &Sensors{
+		SysRoot: "testdata/sys",
+		Tags:    []string{"env:test"},
+	}
+
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+	require.NoError(t, s.Check(agg))
+	agg.Flush()
+
+	// 12 hwmon readings and thresholds, and the temperature and critical
+	// trip point of a thermal zone
+	require.Len(t, metricC, 14)
+	metrics := make([]metric.Metric, 14)
+	for i := range metrics {
+		metrics[i] = <-metricC
+	}
+
+	tags := func(chip, device, label string) []string {
+		return []string{"env:test", "chip:" + chip, "device:" + device, "label:" + label}
+	}
+	zoneTags := func(zone, label string) []string {
+		return []string{"env:test", "chip:" + zone, "label:" + label}
+	}
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature", 45, tags("coretemp", "coretemp.0", "Package id 0"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature.max", 84, tags("coretemp", "coretemp.0", "Package id 0"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature.critical", 100, tags("coretemp", "coretemp.0", "Package id 0"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature", 43.5, tags("coretemp", "coretemp.0", "temp2"))
+	// The coretemp of the second CPU socket has the same labels.
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature", 47, tags("coretemp", "coretemp.1", "Package id 1"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature", 41, tags("coretemp", "coretemp.1", "temp2"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.fan_speed", 1200, tags("nct6775", "nct6775.656", "CPU Fan"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.fan_speed.min", 300, tags("nct6775", "nct6775.656", "CPU Fan"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.voltage", 1.024, tags("nct6775", "nct6775.656", "Vcore"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.voltage.max", 1.744, tags("nct6775", "nct6775.656", "Vcore"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.power", 15.5, tags("nct6775", "nct6775.656", "power1"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature", 38, tags("k8temp", "0000:00:18.3", "temp1"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature", 46, zoneTags("thermal_zone0", "x86_pkg_temp"))
+	testutil.AssertContainsMetricWithTags(t, metrics, "sensors.temperature.critical", 105, zoneTags("thermal_zone0", "x86_pkg_temp"))
+}
+
+func TestSensorsMissingSysfs(t *testing.T) {
+	s := &Sensors{
+		SysRoot: "testdata/missing",
+	}
+
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	err := s.Check(testutil.MockAggregator(metricC))
+	assert.Error(t, err)
+}
@@ -105,3 +105,23 @@ func (m *MockPS) NetConnections() ([]net.ConnectionStat, error) {
 
 	return r0, r1
 }
+
+// NetStat XXX
+func (m *MockPS) NetStat() ([]net.ProtoCountersStat, error) {
+	ret := m.Called()
+
+	r0 := ret.Get(0).([]net.ProtoCountersStat)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
+
+// TCPConnections XXX
+func (m *MockPS) TCPConnections() ([]tcpConnection, error) {
+	ret := m.Called()
+
+	r0 := ret.Get(0).([]tcpConnection)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
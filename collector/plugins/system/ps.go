@@ -17,10 +17,12 @@ type PS interface {
 	DiskUsage(mountPointFilter []string, fstypeExclude []string) ([]*disk.UsageStat, error)
 	NetIO() ([]net.IOCountersStat, error)
 	NetProto() ([]net.ProtoCountersStat, error)
+	NetStat() ([]net.ProtoCountersStat, error)
 	DiskIO() (map[string]disk.IOCountersStat, error)
 	VMStat() (*mem.VirtualMemoryStat, error)
 	SwapStat() (*mem.SwapMemoryStat, error)
 	NetConnections() ([]net.ConnectionStat, error)
+	TCPConnections() ([]tcpConnection, error)
 }
 
 type systemPS struct{}
@@ -99,6 +101,10 @@ func (s *systemPS) NetProto() ([]net.ProtoCountersStat, error) {
 	return net.ProtoCounters(nil)
 }
 
+func (s *systemPS) NetStat() ([]net.ProtoCountersStat, error) {
+	return readProtoCounters(hostProc("net/netstat"))
+}
+
 func (s *systemPS) NetIO() ([]net.IOCountersStat, error) {
 	return net.IOCounters(true)
 }
@@ -107,6 +113,21 @@ func (s *systemPS) NetConnections() ([]net.ConnectionStat, error) {
 	return net.Connections("all")
 }
 
+// TCPConnections reads the sockets of /proc/net/tcp and /proc/net/tcp6
+// directly, as their states don't need the processes owning them.
+func (s *systemPS) TCPConnections() ([]tcpConnection, error) {
+	conns, err := readTCPConnections(hostProc("net/tcp"))
+	if err != nil {
+		return nil, err
+	}
+	// tcp6 is missing when IPv6 is disabled.
+	conns6, err := readTCPConnections(hostProc("net/tcp6"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return append(conns, conns6...), nil
+}
+
 func (s *systemPS) DiskIO() (map[string]disk.IOCountersStat, error) {
 	return disk.IOCounters()
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
 	"github.com/cloudinsight/cloudinsight-agent/common/metric"
 	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
 	"github.com/shirou/gopsutil/cpu"
@@ -49,6 +50,9 @@ type Stats struct {
 	ps  PS
 	cpu *CPUStats
 	io  *DiskIOStats
+
+	// TCPPorts are the local ports whose connections are counted by state.
+	TCPPorts []uint32 `yaml:"tcp_ports"`
 }
 
 // CPUStats XXX
@@ -87,6 +91,12 @@ func (s *Stats) Check(agg metric.Aggregator) error {
 		return err
 	}
 
+	// The TCP metrics are optional, like the protocol counters of
+	// collectNetMetrics, e.g. /proc/net/tcp may be unreadable in a container.
+	if err := s.collectTCPMetrics(agg); err != nil {
+		log.Warnf("Failed to collect TCP metrics: %s", err)
+	}
+
 	if err := s.collectDiskIOMetrics(agg); err != nil {
 		return err
 	}
@@ -0,0 +1,197 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/shirou/gopsutil/net"
+)
+
+// The TCP states of /proc/net/tcp, whose codes start from 1 in this order,
+// see include/net/tcp_states.h.
+var tcpStates = []string{
+	"ESTABLISHED",
+	"SYN_SENT",
+	"SYN_RECV",
+	"FIN_WAIT1",
+	"FIN_WAIT2",
+	"TIME_WAIT",
+	"CLOSE",
+	"CLOSE_WAIT",
+	"LAST_ACK",
+	"LISTEN",
+	"CLOSING",
+}
+
+// The counters of /proc/net/snmp and /proc/net/netstat reported as rates,
+// by protocol and stat.
+var tcpCounters = map[string]map[string]string{
+	"tcp": {
+		"RetransSegs":  "retrans_segs",
+		"InSegs":       "in_segs",
+		"OutSegs":      "out_segs",
+		"InErrs":       "in_errors",
+		"OutRsts":      "out_resets",
+		"AttemptFails": "attempt_fails",
+		"EstabResets":  "estab_resets",
+	},
+	"tcpext": {
+		"ListenOverflows": "listen_overflows",
+		"ListenDrops":     "listen_drops",
+		"TCPBacklogDrop":  "backlog_drops",
+		"TCPTimeouts":     "timeouts",
+		"TCPSynRetrans":   "syn_retrans",
+	},
+}
+
+func (s *Stats) collectTCPMetrics(agg metric.Aggregator) error {
+	conns, err := s.ps.TCPConnections()
+	if err != nil {
+		return fmt.Errorf("error getting tcp connections: %s", err)
+	}
+
+	ports := make(map[uint32]map[string]int, len(s.TCPPorts))
+	for _, port := range s.TCPPorts {
+		ports[port] = make(map[string]int)
+	}
+	states := make(map[string]int)
+	for _, conn := range conns {
+		states[conn.State]++
+		if byState, ok := ports[conn.LocalPort]; ok {
+			byState[conn.State]++
+		}
+	}
+
+	for _, state := range tcpStates {
+		tags := []string{
+			"state:" + strings.ToLower(state),
+		}
+		agg.Add("gauge", metric.NewMetric("system.net.tcp.connections", states[state], tags))
+
+		for port, byState := range ports {
+			tags := []string{
+				"port:" + strconv.FormatUint(uint64(port), 10),
+				"state:" + strings.ToLower(state),
+			}
+			agg.Add("gauge", metric.NewMetric("system.net.tcp.port_connections", byState[state], tags))
+		}
+	}
+
+	// Ignore the counters if they are unavailable, like collectNetMetrics.
+	netprotos, _ := s.ps.NetProto()
+	netstats, _ := s.ps.NetStat()
+	fields := make(map[string]interface{})
+	for _, proto := range append(netprotos, netstats...) {
+		names, ok := tcpCounters[strings.ToLower(proto.Protocol)]
+		if !ok {
+			continue
+		}
+		for stat, name := range names {
+			if value, ok := proto.Stats[stat]; ok {
+				fields[name] = value
+			}
+		}
+	}
+	agg.AddMetrics("rate", "system.net.tcp", fields, nil, "")
+
+	return nil
+}
+
+// tcpConnection is a socket of /proc/net/tcp or /proc/net/tcp6.
+type tcpConnection struct {
+	LocalPort uint32
+	State     string
+}
+
+// readTCPConnections reads the local port and the state of the sockets of a
+// file like /proc/net/tcp, the state is the fourth column, e.g.
+// sl  local_address rem_address   st tx_queue rx_queue ...
+// 0: 00000000:0050 00000000:0000 0A 00000000:00000000 ...
+func readTCPConnections(filename string) ([]tcpConnection, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var conns []tcpConnection
+	sc := bufio.NewScanner(f)
+	// Skip the header.
+	sc.Scan()
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		i := strings.LastIndex(fields[1], ":")
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.ParseUint(fields[1][i+1:], 16, 16)
+		if err != nil {
+			continue
+		}
+		code, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil || code < 1 || int(code) > len(tcpStates) {
+			continue
+		}
+		conns = append(conns, tcpConnection{
+			LocalPort: uint32(port),
+			State:     tcpStates[code-1],
+		})
+	}
+	return conns, sc.Err()
+}
+
+// readProtoCounters reads the counters of a file like /proc/net/netstat,
+// which has a line of stat names followed by a line of values per protocol.
+func readProtoCounters(filename string) ([]net.ProtoCountersStat, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines)%2 != 0 {
+		return nil, fmt.Errorf("%s is not formatted correctly, expected pairs of lines", filename)
+	}
+
+	var stats []net.ProtoCountersStat
+	for i := 0; i < len(lines); i += 2 {
+		names := strings.Fields(lines[i])
+		values := strings.Fields(lines[i+1])
+		if len(names) == 0 || len(names) != len(values) || names[0] != values[0] {
+			return nil, fmt.Errorf("%s is not formatted correctly at line %d", filename, i+1)
+		}
+
+		stat := net.ProtoCountersStat{
+			Protocol: strings.ToLower(strings.TrimSuffix(names[0], ":")),
+			Stats:    make(map[string]int64, len(names)-1),
+		}
+		for j := 1; j < len(names); j++ {
+			value, err := strconv.ParseInt(values[j], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			stat.Stats[names[j]] = value
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// hostProc returns the path under /proc, which can be overridden by the
+// HOST_PROC environment variable like gopsutil, e.g. in a container.
+func hostProc(path string) string {
+	root := os.Getenv("HOST_PROC")
+	if root == "" {
+		root = "/proc"
+	}
+	return filepath.Join(root, path)
+}
@@ -0,0 +1,179 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectTCPMetrics(t *testing.T) {
+	var mps MockPS
+	defer mps.AssertExpectations(t)
+
+	os.Setenv("HOST_PROC", "testdata")
+	defer os.Unsetenv("HOST_PROC")
+	conns, err := (&systemPS{}).TCPConnections()
+	require.NoError(t, err)
+
+	mps.On("TCPConnections").Return(conns, nil)
+	mps.On("NetProto").Return([]net.ProtoCountersStat{}, nil)
+	mps.On("NetStat").Return([]net.ProtoCountersStat{}, nil)
+
+	s := &Stats{
+		ps:       &mps,
+		TCPPorts: []uint32{80, 443},
+	}
+
+	metricC := make(chan metric.Metric, 100)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+	require.NoError(t, s.collectTCPMetrics(agg))
+	agg.Flush()
+
+	// 11 states globally and for each port
+	require.Len(t, metricC, 33)
+	metrics := make([]metric.Metric, 33)
+	for i := range metrics {
+		metrics[i] = <-metricC
+	}
+
+	// The listeners of port 80 are separate sockets, e.g. with SO_REUSEPORT.
+	global := map[string]float64{
+		"established": 2,
+		"time_wait":   1,
+		"listen":      2,
+		"close_wait":  0,
+	}
+	for state, value := range global {
+		testutil.AssertContainsMetricWithTags(t, metrics, "system.net.tcp.connections", value, []string{"state:" + state})
+	}
+
+	port80 := map[string]float64{
+		"established": 1,
+		"time_wait":   1,
+		"listen":      2,
+		"syn_recv":    0,
+	}
+	for state, value := range port80 {
+		testutil.AssertContainsMetricWithTags(t, metrics, "system.net.tcp.port_connections", value, []string{"port:80", "state:" + state})
+	}
+	testutil.AssertContainsMetricWithTags(t, metrics, "system.net.tcp.port_connections", 0, []string{"port:443", "state:established"})
+}
+
+func TestCollectTCPCounters(t *testing.T) {
+	netstats, err := readProtoCounters("testdata/net/netstat")
+	require.NoError(t, err)
+
+	netprotos := []net.ProtoCountersStat{
+		{
+			Protocol: "tcp",
+			Stats: map[string]int64{
+				"RetransSegs": 100,
+				"InSegs":      5000,
+				"OutSegs":     4000,
+			},
+		},
+	}
+
+	var mps MockPS
+	mps.On("TCPConnections").Return([]tcpConnection{}, nil)
+	mps.On("NetProto").Return(netprotos, nil)
+	mps.On("NetStat").Return(netstats, nil)
+	s := &Stats{
+		ps: &mps,
+	}
+
+	netprotos2 := []net.ProtoCountersStat{
+		{
+			Protocol: "tcp",
+			Stats: map[string]int64{
+				"RetransSegs": 110, // increased by 10
+				"InSegs":      5000,
+				"OutSegs":     4000,
+			},
+		},
+	}
+	netstats2 := []net.ProtoCountersStat{
+		{
+			Protocol: "tcpext",
+			Stats: map[string]int64{
+				"ListenOverflows": 15, // increased by 3
+				"ListenDrops":     20, // increased by 5
+				"TCPTimeouts":     40,
+				"TCPSynRetrans":   7,
+				"TCPBacklogDrop":  3,
+			},
+		},
+	}
+
+	var mps2 MockPS
+	mps2.On("TCPConnections").Return([]tcpConnection{}, nil)
+	mps2.On("NetProto").Return(netprotos2, nil)
+	mps2.On("NetStat").Return(netstats2, nil)
+	s2 := &Stats{
+		ps: &mps2,
+	}
+
+	fields := map[string]float64{
+		"system.net.tcp.retrans_segs":     10,
+		"system.net.tcp.in_segs":          0,
+		"system.net.tcp.listen_overflows": 3,
+		"system.net.tcp.listen_drops":     5,
+		"system.net.tcp.timeouts":         0,
+	}
+	// 11 state gauges and 8 rates
+	testutil.AssertCheckWithRateMetrics(t, s.collectTCPMetrics, s2.collectTCPMetrics, 19, fields, nil)
+}
+
+func TestNetStat(t *testing.T) {
+	os.Setenv("HOST_PROC", "testdata")
+	defer os.Unsetenv("HOST_PROC")
+
+	stats, err := (&systemPS{}).NetStat()
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	assert.Equal(t, "tcpext", stats[0].Protocol)
+	assert.Equal(t, int64(12), stats[0].Stats["ListenOverflows"])
+	assert.Equal(t, int64(15), stats[0].Stats["ListenDrops"])
+	assert.Equal(t, "ipext", stats[1].Protocol)
+	assert.Equal(t, int64(72266069), stats[1].Stats["InOctets"])
+}
+
+func TestTCPConnections(t *testing.T) {
+	os.Setenv("HOST_PROC", "testdata")
+	defer os.Unsetenv("HOST_PROC")
+
+	conns, err := (&systemPS{}).TCPConnections()
+	require.NoError(t, err)
+	require.Len(t, conns, 5)
+
+	assert.Equal(t, tcpConnection{LocalPort: 80, State: "LISTEN"}, conns[0])
+	assert.Equal(t, tcpConnection{LocalPort: 80, State: "ESTABLISHED"}, conns[2])
+	assert.Equal(t, tcpConnection{LocalPort: 80, State: "TIME_WAIT"}, conns[3])
+	assert.Equal(t, tcpConnection{LocalPort: 22, State: "ESTABLISHED"}, conns[4])
+}
+
+func TestTCPConnectionsWithoutIPv6(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile("testdata/net/tcp")
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "net"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "net", "tcp"), data, 0644))
+
+	os.Setenv("HOST_PROC", dir)
+	defer os.Unsetenv("HOST_PROC")
+
+	conns, err := (&systemPS{}).TCPConnections()
+	require.NoError(t, err)
+	assert.Len(t, conns, 4)
+}
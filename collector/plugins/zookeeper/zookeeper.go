@@ -0,0 +1,258 @@
+package zookeeper
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudinsight/cloudinsight-agent/collector"
+	"github.com/cloudinsight/cloudinsight-agent/common/log"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+	"github.com/cloudinsight/cloudinsight-agent/common/plugin"
+)
+
+// NewZooKeeper XXX
+func NewZooKeeper(conf plugin.InitConfig) plugin.Plugin {
+	return &ZooKeeper{
+		modes: make(map[string]string),
+	}
+}
+
+// ZooKeeper XXX
+type ZooKeeper struct {
+	Servers []string
+	Timeout int
+	Tags    []string
+
+	modes map[string]string
+}
+
+const (
+	defaultServer  = "localhost:2181"
+	defaultTimeout = 3
+)
+
+var (
+	// GAUGES XXX
+	GAUGES = map[string]string{
+		"zk_avg_latency":                "zookeeper.latency.avg",
+		"zk_max_latency":                "zookeeper.latency.max",
+		"zk_min_latency":                "zookeeper.latency.min",
+		"zk_num_alive_connections":      "zookeeper.connections",
+		"zk_outstanding_requests":       "zookeeper.outstanding_requests",
+		"zk_znode_count":                "zookeeper.znode_count",
+		"zk_watch_count":                "zookeeper.watch_count",
+		"zk_ephemerals_count":           "zookeeper.ephemerals_count",
+		"zk_approximate_data_size":      "zookeeper.approximate_data_size",
+		"zk_open_file_descriptor_count": "zookeeper.open_file_descriptor_count",
+		"zk_max_file_descriptor_count":  "zookeeper.max_file_descriptor_count",
+
+		// only exposed by the leader
+		"zk_followers":        "zookeeper.followers",
+		"zk_synced_followers": "zookeeper.synced_followers",
+		"zk_pending_syncs":    "zookeeper.pending_syncs",
+	}
+
+	// RATES XXX
+	RATES = map[string]string{
+		"zk_packets_received": "zookeeper.packets_received",
+		"zk_packets_sent":     "zookeeper.packets_sent",
+	}
+
+	// Keys found in the output of `stat`, mapped to the ones of `mntr`.
+	statKeys = map[string]string{
+		"Received":    "zk_packets_received",
+		"Sent":        "zk_packets_sent",
+		"Connections": "zk_num_alive_connections",
+		"Outstanding": "zk_outstanding_requests",
+		"Node count":  "zk_znode_count",
+		"Mode":        "zk_server_state",
+	}
+)
+
+// Check XXX
+func (z *ZooKeeper) Check(agg metric.Aggregator) error {
+	servers := z.Servers
+	if len(servers) == 0 {
+		servers = []string{defaultServer}
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		err := z.collectServer(server, agg)
+		if err != nil {
+			log.Errorf("Failed to collect zookeeper server %s. %s", server, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (z *ZooKeeper) collectServer(server string, agg metric.Aggregator) error {
+	stats, err := z.getStats(server)
+	if err != nil {
+		return err
+	}
+
+	mode := stats["zk_server_state"]
+	if mode == "" {
+		mode = "unknown"
+	}
+	tags := append(z.getTags(server), "mode:"+mode)
+	z.checkModeChange(server, mode, agg)
+
+	for key, value := range stats {
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		if name, ok := GAUGES[key]; ok {
+			agg.Add("gauge", metric.NewMetric(name, val, tags))
+		}
+
+		if name, ok := RATES[key]; ok {
+			agg.Add("rate", metric.NewMetric(name, val, tags))
+		}
+	}
+	return nil
+}
+
+// getStats sends `mntr` to the server, and falls back to `stat` when `mntr`
+// is unsupported (< 3.4.0) or not in the 4lw.commands.whitelist.
+func (z *ZooKeeper) getStats(server string) (map[string]string, error) {
+	out, err := z.sendCommand(server, "mntr")
+	if err != nil {
+		return nil, err
+	}
+	stats := parseMntr(out)
+	if len(stats) > 0 {
+		return stats, nil
+	}
+
+	log.Debugf("mntr is unavailable on zookeeper server %s, falling back to stat", server)
+	out, err = z.sendCommand(server, "stat")
+	if err != nil {
+		return nil, err
+	}
+	stats = parseStat(out)
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("unexpected response of stat: %q", out)
+	}
+	return stats, nil
+}
+
+func (z *ZooKeeper) sendCommand(server, command string) (string, error) {
+	timeout := time.Duration(z.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout * time.Second
+	}
+
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return "", err
+	}
+	_, err = conn.Write([]byte(command))
+	if err != nil {
+		return "", err
+	}
+
+	out, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func (z *ZooKeeper) checkModeChange(server, mode string, agg metric.Aggregator) {
+	lastMode, ok := z.modes[server]
+	z.modes[server] = mode
+	if !ok || lastMode == mode {
+		return
+	}
+
+	agg.AddEvent(metric.Event{
+		Title:          fmt.Sprintf("ZooKeeper %s changed mode from %s to %s", server, lastMode, mode),
+		Text:           fmt.Sprintf("ZooKeeper %s is now a %s, it was a %s.", server, mode, lastMode),
+		AlertType:      "info",
+		AggregationKey: "zookeeper:" + server,
+		SourceTypeName: "zookeeper",
+		Tags:           append(z.getTags(server), "mode:"+mode),
+	})
+}
+
+func (z *ZooKeeper) getTags(server string) []string {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host = server
+	}
+
+	var tags []string
+	tags = append(tags, z.Tags...)
+	tags = append(tags, "zk_host:"+host)
+	if port != "" {
+		tags = append(tags, "zk_port:"+port)
+	}
+	return tags
+}
+
+// parseMntr parses the output of `mntr`, which looks like:
+// zk_version	3.4.9-1757313, built on 08/23/2016 06:50 GMT
+// zk_avg_latency	0
+func parseMntr(out string) map[string]string {
+	stats := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		parts := strings.SplitN(sc.Text(), "\t", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "zk_") {
+			continue
+		}
+		stats[parts[0]] = strings.TrimSpace(parts[1])
+	}
+	return stats
+}
+
+// parseStat parses the output of `stat`, which looks like:
+// Latency min/avg/max: 0/0/0
+// Received: 3
+// Mode: standalone
+func parseStat(out string) map[string]string {
+	stats := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		parts := strings.SplitN(sc.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := parts[0], strings.TrimSpace(parts[1])
+
+		if key == "Latency min/avg/max" {
+			latency := strings.Split(value, "/")
+			if len(latency) == 3 {
+				stats["zk_min_latency"] = latency[0]
+				stats["zk_avg_latency"] = latency[1]
+				stats["zk_max_latency"] = latency[2]
+			}
+			continue
+		}
+
+		if name, ok := statKeys[key]; ok {
+			stats[name] = value
+		}
+	}
+	return stats
+}
+
+func init() {
+	collector.Add("zookeeper", NewZooKeeper)
+}
@@ -0,0 +1,175 @@
+package zookeeper
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudinsight/cloudinsight-agent/common"
+	"github.com/cloudinsight/cloudinsight-agent/common/metric"
+)
+
+const (
+	mntrResult = `zk_version	3.4.9-1757313, built on 08/23/2016 06:50 GMT
+zk_avg_latency	1
+zk_max_latency	12
+zk_min_latency	0
+zk_packets_received	70
+zk_packets_sent	69
+zk_num_alive_connections	2
+zk_outstanding_requests	0
+zk_server_state	leader
+zk_znode_count	4
+zk_watch_count	1
+zk_ephemerals_count	0
+zk_approximate_data_size	27
+zk_open_file_descriptor_count	23
+zk_max_file_descriptor_count	1024
+zk_followers	2
+zk_synced_followers	2
+zk_pending_syncs	0
+`
+
+	statResult = `Zookeeper version: 3.3.6-1366786, built on 07/29/2012 06:22 GMT
+Clients:
+ /127.0.0.1:60218[0](queued=0,recved=1,sent=0)
+
+Latency min/avg/max: 0/2/15
+Received: 3
+Sent: 2
+Connections: 1
+Outstanding: 0
+Zxid: 0x100000000
+Mode: follower
+Node count: 4
+`
+)
+
+// serve starts a fake zookeeper server answering the four letter words.
+func serve(t *testing.T, responses map[string]string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 4)
+			if _, err := conn.Read(buf); err == nil {
+				conn.Write([]byte(responses[string(buf)]))
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestCheckWithMntr(t *testing.T) {
+	addr := serve(t, map[string]string{"mntr": mntrResult})
+	host, port, _ := net.SplitHostPort(addr)
+	z := NewZooKeeper(nil).(*ZooKeeper)
+	z.Servers = []string{addr}
+	z.Tags = []string{"service:zookeeper"}
+
+	fields := map[string]float64{
+		"zookeeper.latency.avg":                1,
+		"zookeeper.latency.max":                12,
+		"zookeeper.latency.min":                0,
+		"zookeeper.connections":                2,
+		"zookeeper.outstanding_requests":       0,
+		"zookeeper.znode_count":                4,
+		"zookeeper.watch_count":                1,
+		"zookeeper.ephemerals_count":           0,
+		"zookeeper.approximate_data_size":      27,
+		"zookeeper.open_file_descriptor_count": 23,
+		"zookeeper.max_file_descriptor_count":  1024,
+		"zookeeper.followers":                  2,
+		"zookeeper.synced_followers":           2,
+		"zookeeper.pending_syncs":              0,
+	}
+	tags := []string{"service:zookeeper", "zk_host:" + host, "zk_port:" + port, "mode:leader"}
+	testutil.AssertCheckWithMetrics(t, z.Check, 14, fields, tags)
+}
+
+func TestCheckWithStat(t *testing.T) {
+	addr := serve(t, map[string]string{"stat": statResult})
+	host, port, _ := net.SplitHostPort(addr)
+	z := NewZooKeeper(nil).(*ZooKeeper)
+	z.Servers = []string{addr}
+
+	fields := map[string]float64{
+		"zookeeper.latency.avg":          2,
+		"zookeeper.latency.max":          15,
+		"zookeeper.latency.min":          0,
+		"zookeeper.connections":          1,
+		"zookeeper.outstanding_requests": 0,
+		"zookeeper.znode_count":          4,
+	}
+	tags := []string{"zk_host:" + host, "zk_port:" + port, "mode:follower"}
+	testutil.AssertCheckWithMetrics(t, z.Check, 6, fields, tags)
+}
+
+func TestCheckWithUnreachableServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	z := NewZooKeeper(nil).(*ZooKeeper)
+	z.Servers = []string{addr}
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+	assert.Error(t, z.Check(agg))
+}
+
+func TestModeChangeEvent(t *testing.T) {
+	z := NewZooKeeper(nil).(*ZooKeeper)
+	metricC := make(chan metric.Metric, 10)
+	defer close(metricC)
+	agg := testutil.MockAggregator(metricC)
+
+	z.checkModeChange("localhost:2181", "follower", agg)
+	z.checkModeChange("localhost:2181", "follower", agg)
+	agg.Flush()
+	assert.Len(t, metricC, 0)
+
+	z.checkModeChange("localhost:2181", "leader", agg)
+	agg.Flush()
+	require.Len(t, metricC, 1)
+	m := <-metricC
+	e, ok := m.Value.(metric.Event)
+	require.True(t, ok)
+	assert.Equal(t, "ZooKeeper localhost:2181 changed mode from follower to leader", e.Title)
+	assert.Equal(t, "zookeeper", e.SourceTypeName)
+	assert.Equal(t, []string{"zk_host:localhost", "zk_port:2181", "mode:leader"}, e.Tags)
+}
+
+func TestParseMntr(t *testing.T) {
+	stats := parseMntr("This ZooKeeper instance is not currently serving requests\n")
+	assert.Len(t, stats, 0)
+
+	stats = parseMntr(mntrResult)
+	assert.Equal(t, "leader", stats["zk_server_state"])
+	assert.Equal(t, "70", stats["zk_packets_received"])
+}
+
+func TestParseStat(t *testing.T) {
+	stats := parseStat(statResult)
+	expected := map[string]string{
+		"zk_min_latency":           "0",
+		"zk_avg_latency":           "2",
+		"zk_max_latency":           "15",
+		"zk_packets_received":      "3",
+		"zk_packets_sent":          "2",
+		"zk_num_alive_connections": "1",
+		"zk_outstanding_requests":  "0",
+		"zk_server_state":          "follower",
+		"zk_znode_count":           "4",
+	}
+	assert.Equal(t, expected, stats)
+}
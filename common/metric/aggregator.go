@@ -22,6 +22,8 @@ type Aggregator interface {
 
 	SubmitPackets(packet string)
 	Add(metricType string, m Metric)
+	AddEvent(e Event)
+	AddServiceCheck(sc ServiceCheck)
 	Flush()
 }
 
@@ -73,6 +75,8 @@ type aggregator struct {
 
 	metrics              chan Metric
 	context              map[Context]Generator
+	events               []Event
+	serviceChecks        []ServiceCheck
 	interval             float64
 	hostname             string
 	formatter            Formatter
@@ -168,6 +172,32 @@ func (agg *aggregator) Add(metricType string, m Metric) {
 	generator.Sample(value, m.Timestamp)
 }
 
+func (agg *aggregator) AddEvent(e Event) {
+	if e.Host == "" {
+		e.Host = agg.hostname
+	}
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().Unix()
+	}
+
+	agg.Lock()
+	defer agg.Unlock()
+	agg.events = append(agg.events, e)
+}
+
+func (agg *aggregator) AddServiceCheck(sc ServiceCheck) {
+	if sc.Hostname == "" {
+		sc.Hostname = agg.hostname
+	}
+	if sc.Timestamp == 0 {
+		sc.Timestamp = time.Now().Unix()
+	}
+
+	agg.Lock()
+	defer agg.Unlock()
+	agg.serviceChecks = append(agg.serviceChecks, sc)
+}
+
 func (agg *aggregator) Flush() {
 	timestamp := time.Now().Unix()
 	for ctx, generator := range agg.context {
@@ -184,6 +214,19 @@ func (agg *aggregator) Flush() {
 		}
 	}
 
+	agg.Lock()
+	events := agg.events
+	agg.events = nil
+	serviceChecks := agg.serviceChecks
+	agg.serviceChecks = nil
+	agg.Unlock()
+	for _, e := range events {
+		agg.metrics <- e.toMetric(agg.formatter)
+	}
+	for _, sc := range serviceChecks {
+		agg.metrics <- sc.toMetric(agg.formatter)
+	}
+
 	// Log a warning regarding metrics with old timestamps being submitted
 	if agg.discardedOldPoints > 0 {
 		log.Warnf("%d points were discarded as a result of having an old timestamp", agg.discardedOldPoints)
@@ -168,6 +168,61 @@ func TestFlush(t *testing.T) {
 	assert.Equal(t, now, testm.Timestamp)
 }
 
+func TestAddEvent(t *testing.T) {
+	a := aggregator{
+		metrics:  make(chan Metric, 10),
+		context:  make(map[Context]Generator),
+		hostname: "test",
+	}
+	defer close(a.metrics)
+
+	a.AddEvent(Event{
+		Title: "agg.test",
+		Tags:  []string{"agg:test"},
+	})
+	assert.Len(t, a.events, 1)
+
+	a.Flush()
+	assert.Len(t, a.events, 0)
+	assert.Len(t, a.metrics, 1)
+
+	testm := <-a.metrics
+	assert.Equal(t, "event", testm.Type)
+	e, ok := testm.Value.(Event)
+	assert.True(t, ok)
+	assert.Equal(t, "agg.test", e.Title)
+	assert.Equal(t, "test", e.Host)
+	assert.Equal(t, []string{"agg:test"}, e.Tags)
+	assert.NotZero(t, e.Timestamp)
+}
+
+func TestAddServiceCheck(t *testing.T) {
+	a := aggregator{
+		metrics:  make(chan Metric, 10),
+		context:  make(map[Context]Generator),
+		hostname: "test",
+	}
+	defer close(a.metrics)
+
+	a.AddServiceCheck(NewServiceCheck("agg.test", StatusCritical, "down", []string{"agg:test"}))
+	assert.Len(t, a.serviceChecks, 1)
+
+	a.Flush()
+	assert.Len(t, a.serviceChecks, 0)
+	assert.Len(t, a.metrics, 1)
+
+	testm := <-a.metrics
+	assert.Equal(t, "service_check", testm.Type)
+	sc, ok := testm.Value.(ServiceCheck)
+	assert.True(t, ok)
+	assert.Equal(t, "agg.test", sc.Check)
+	assert.Equal(t, StatusCritical, sc.Status)
+	assert.Equal(t, "down", sc.Message)
+	assert.Equal(t, "test", sc.Hostname)
+	assert.Equal(t, []string{"agg:test"}, sc.Tags)
+	assert.NotZero(t, sc.Timestamp)
+}
+
 func TestCounterNormalization(t *testing.T) {
 	a := aggregator{
 		metrics:  make(chan Metric, 10),
@@ -0,0 +1,35 @@
+package metric
+
+import "fmt"
+
+// Event represents something that happened at a specific point in time,
+// e.g. a role change of a cluster member.
+type Event struct {
+	Title          string   `json:"msg_title"`
+	Text           string   `json:"msg_text"`
+	Timestamp      int64    `json:"timestamp"`
+	Priority       string   `json:"priority,omitempty"`
+	Host           string   `json:"host"`
+	Tags           []string `json:"tags,omitempty"`
+	AlertType      string   `json:"alert_type,omitempty"`
+	AggregationKey string   `json:"aggregation_key,omitempty"`
+	SourceTypeName string   `json:"source_type_name,omitempty"`
+}
+
+// String XXX
+func (e *Event) String() string {
+	return fmt.Sprintf("event %s %v", e.Title, e.Tags)
+}
+
+// toMetric wraps the event into a Metric, so that it can be sent
+// through the same channel with the metrics.
+func (e Event) toMetric(formatter Formatter) Metric {
+	return Metric{
+		Name:      "event",
+		Value:     e,
+		Hostname:  e.Host,
+		Timestamp: e.Timestamp,
+		Type:      "event",
+		Formatter: formatter,
+	}
+}
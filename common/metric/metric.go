@@ -107,6 +107,12 @@ func (m *Metric) IsExpired(timestamp, expirySeconds int64) bool {
 
 // String XXX
 func (m *Metric) String() string {
+	if e, ok := m.Value.(Event); ok {
+		return e.String()
+	}
+	if sc, ok := m.Value.(ServiceCheck); ok {
+		return sc.String()
+	}
 	return fmt.Sprintf("%s %f %v", m.Name, m.Value, m.Tags)
 }
 
@@ -0,0 +1,49 @@
+package metric
+
+import "fmt"
+
+// The statuses of service checks
+const (
+	StatusOK       = 0
+	StatusWarning  = 1
+	StatusCritical = 2
+	StatusUnknown  = 3
+)
+
+// ServiceCheck reports the status of a service, e.g. whether it's reachable.
+type ServiceCheck struct {
+	Check     string   `json:"check"`
+	Hostname  string   `json:"host_name"`
+	Timestamp int64    `json:"timestamp"`
+	Status    int      `json:"status"`
+	Message   string   `json:"message,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// NewServiceCheck XXX
+func NewServiceCheck(check string, status int, message string, tags []string) ServiceCheck {
+	return ServiceCheck{
+		Check:   check,
+		Status:  status,
+		Message: message,
+		Tags:    tags,
+	}
+}
+
+// String XXX
+func (sc *ServiceCheck) String() string {
+	return fmt.Sprintf("service_check %s %d %v", sc.Check, sc.Status, sc.Tags)
+}
+
+// toMetric wraps the service check into a Metric, so that it can be sent
+// through the same channel with the metrics.
+func (sc ServiceCheck) toMetric(formatter Formatter) Metric {
+	return Metric{
+		Name:      sc.Check,
+		Value:     sc,
+		Hostname:  sc.Hostname,
+		Timestamp: sc.Timestamp,
+		Type:      "service_check",
+		Formatter: formatter,
+	}
+}
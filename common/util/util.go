@@ -2,7 +2,10 @@ package util
 
 import (
 	"hash/fnv"
+	"io/ioutil"
 	"math"
+	"strconv"
+	"strings"
 
 	"github.com/cloudinsight/cloudinsight-agent/common/log"
 	yaml "gopkg.in/yaml.v2"
@@ -69,3 +72,28 @@ func StringInSlice(str string, list []string) bool {
 	}
 	return false
 }
+
+// ReadString reads a file containing a single line, e.g. of sysfs or procfs,
+// it returns false if the file doesn't exist or is empty.
+func ReadString(path string) (string, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	s := strings.TrimSpace(string(data))
+	return s, s != ""
+}
+
+// ReadValue reads a file containing a single number, it returns false if the
+// file doesn't exist or isn't a number.
+func ReadValue(path string) (float64, bool) {
+	s, ok := ReadString(path)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
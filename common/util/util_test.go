@@ -1,9 +1,13 @@
 package util
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCast(t *testing.T) {
@@ -164,3 +168,31 @@ func TestStringInSlice(t *testing.T) {
 		}
 	}
 }
+
+func TestReadValue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "util")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"value":  "42\n",
+		"string": "coretemp\n",
+		"empty":  "\n",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	v, ok := ReadValue(filepath.Join(dir, "value"))
+	assert.True(t, ok)
+	assert.Equal(t, 42.0, v)
+	_, ok = ReadValue(filepath.Join(dir, "string"))
+	assert.False(t, ok)
+	_, ok = ReadValue(filepath.Join(dir, "missing"))
+	assert.False(t, ok)
+
+	s, ok := ReadString(filepath.Join(dir, "string"))
+	assert.True(t, ok)
+	assert.Equal(t, "coretemp", s)
+	_, ok = ReadString(filepath.Join(dir, "empty"))
+	assert.False(t, ok)
+}